@@ -0,0 +1,85 @@
+package zenq
+
+// WriteBatch writes all the values to the queue in order
+// A contiguous range of indices is claimed for the entire batch via a single increment of the writer index,
+// thereafter the values are committed slot by slot as soon as their turns come
+// It returns the number of values written which falls short of len(values) only if the queue got closed
+// or values got dropped as per the DropNewest policy, which drops and evicts values one by one just like DropOldest
+func (self *ZenQ[T]) WriteBatch(values []T) (n int, queueClosedForWrites bool) {
+	if Load8(&self.globalState) != StateOpen {
+		queueClosedForWrites = true
+		return
+	}
+	if self.fullPolicy == DropNewest || self.fullPolicy == DropOldest {
+		for idx := 0; idx < len(values) && !queueClosedForWrites; idx++ {
+			var written bool
+			if written, queueClosedForWrites = self.writeOrDrop(values[idx]); written {
+				n++
+			}
+		}
+		return
+	}
+	r, writerIndex := self.claim(uint32(len(values)))
+	for idx := range values {
+		// every claimed index is gone through even after the queue turns out to be closed
+		// so that the values of the ones before the closing commit are still committed
+		if self.writeAt(r, writerIndex+uint32(idx)+1, values[idx]) {
+			queueClosedForWrites = true
+		} else {
+			n++
+		}
+	}
+	return
+}
+
+// ReadBatch reads upto len(dst) values from the queue into dst in order
+// All values available at the time of the call are claimed at once via a single CAS of the reader index,
+// in case none are available then it blocks until a single value can be read just like Read()
+// It returns the number of values read and queueOpen = false only if the queue is closed and nothing was read
+func (self *ZenQ[T]) ReadBatch(dst []T) (n int, queueOpen bool) {
+	if len(dst) == 0 {
+		return 0, Load8(&self.globalState) != StateFullyClosed
+	}
+	// only a closed queue whose claimed indices all got skipped yields nothing, hence claim once again
+	for n == 0 {
+		// every index before the closing commit was claimed already just like in read()
+		if Load8(&self.globalState) == StateFullyClosed {
+			return 0, false
+		}
+		var (
+			r                    = self.readRing.Load()
+			readerIndex, claimed uint32
+		)
+		for {
+			readerIndex = self.readerIndex.Load()
+			if available := int(int32(self.writerIndex.Load() - readerIndex)); available <= 0 {
+				// nothing to claim in bulk, wait for a single value instead
+				readerIndex, claimed = self.readerIndex.Add(1)-1, 1
+				break
+			} else if available < len(dst) {
+				claimed = uint32(available)
+			} else {
+				claimed = uint32(len(dst))
+			}
+			if self.readerIndex.CompareAndSwap(readerIndex, readerIndex+claimed) {
+				break
+			}
+		}
+		queueOpen = true
+		for idx := uint32(1); idx <= claimed; idx++ {
+			// every claimed index is gone through even after the queue turns out to be closed
+			// so that the values of the ones before the closing commit are still read
+			// whereas the indices skipped once the queue is closed are merely left out of the batch
+			if data, open, skipped := self.readAt(r, readerIndex+idx); open && !skipped {
+				dst[n] = data
+				n++
+			} else if !open {
+				queueOpen = n > 0
+			}
+		}
+		if !queueOpen {
+			return
+		}
+	}
+	return
+}
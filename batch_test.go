@@ -0,0 +1,100 @@
+package zenq_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+func TestBatchWhileContending(t *testing.T) {
+	const writers, readers, perWriter, batchSize = 4, 3, 50000, 37
+	zq := zenq.New[int](16)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			batch := make([]int, 0, batchSize)
+			for i := 0; i < perWriter; i++ {
+				// batches larger than the queue are written as its slots free up
+				if batch = append(batch, w*perWriter+i); len(batch) == cap(batch) || i == perWriter-1 {
+					if n, closed := zq.WriteBatch(batch); n != len(batch) || closed {
+						t.Errorf("wrote %d out of %d, closed %t", n, len(batch), closed)
+					}
+					batch = batch[:0]
+				}
+			}
+		}(w)
+	}
+	go func() {
+		wg.Wait()
+		zq.Close()
+	}()
+
+	var mutex sync.Mutex
+	seen := make([]bool, writers*perWriter)
+	var rg sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		rg.Add(1)
+		go func() {
+			defer rg.Done()
+			dst := make([]int, 20)
+			for k := 0; ; k++ {
+				// single value reads interleave with batch ones
+				n, queueOpen := zq.ReadBatch(dst[:1+(len(dst)-1)*(k%2)])
+				if !queueOpen {
+					return
+				}
+				mutex.Lock()
+				for _, value := range dst[:n] {
+					if seen[value] {
+						t.Errorf("read %d twice", value)
+					}
+					seen[value] = true
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	rg.Wait()
+	for value, read := range seen {
+		if !read {
+			t.Fatalf("%d never read", value)
+		}
+	}
+}
+
+func TestBatchOrder(t *testing.T) {
+	const numItems = 1000
+	zq := zenq.New[int](8)
+	go func() {
+		values := make([]int, numItems)
+		for i := range values {
+			values[i] = i
+		}
+		zq.WriteBatch(values)
+		zq.Close()
+	}()
+
+	dst, expected := make([]int, 5), 0
+	for {
+		n, queueOpen := zq.ReadBatch(dst)
+		if !queueOpen {
+			break
+		}
+		for _, value := range dst[:n] {
+			if value != expected {
+				t.Fatalf("read %d, expected %d", value, expected)
+			}
+			expected++
+		}
+	}
+	if expected != numItems {
+		t.Fatalf("read %d out of %d values", expected, numItems)
+	}
+	if n, closed := zq.WriteBatch([]int{1}); n != 0 || !closed {
+		t.Fatalf("wrote %d values to a closed queue", n)
+	}
+}
@@ -0,0 +1,112 @@
+package zenq
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Sequence identifies a slot claimed by a writer via Claim() or ClaimBatch()
+type Sequence struct {
+	idx uint32
+	// the ring serving the index, an index might be served by an older ring than the current one after a resize
+	ring unsafe.Pointer
+}
+
+// Add returns the sequence n slots after this one, used for accessing the slots claimed via ClaimBatch()
+func (self Sequence) Add(n int) Sequence {
+	self.idx += uint32(n)
+	return self
+}
+
+// Claim claims the next slot of the queue and returns a pointer to its item so that it can be filled in place
+// thereby saving the copy of a value passed to Write()
+// The item becomes visible to readers only once published via Publish(), hence every claimed slot must be published
+// without much delay as readers block on it meanwhile
+// The item still holds whatever value was read from the slot a lap before and the pointer must not be used after publishing
+func (self *ZenQ[T]) Claim() (item *T, seq Sequence, queueClosedForWrites bool) {
+	if Load8(&self.globalState) != StateOpen {
+		queueClosedForWrites = true
+		return
+	}
+	r, writerIndex := self.claim(1)
+	idx := writerIndex + 1
+	if r, queueClosedForWrites = self.claimAt(r, idx); queueClosedForWrites {
+		return
+	}
+	return &r.slotAt(idx).item, Sequence{idx, unsafe.Pointer(r)}, false
+}
+
+// Publish commits the item of a slot claimed via Claim() making it available to readers
+func (self *ZenQ[T]) Publish(seq Sequence) {
+	self.commit((*ring[T])(seq.ring).slotAt(seq.idx), seq.idx, SlotCommitted)
+}
+
+// ClaimBatch claims n contiguous slots via a single increment of the writer index
+// Their items are accessed via Item() with sequences ranging from first to first.Add(n-1) and published all at once
+// via PublishBatch()
+// Either all n slots are claimed or none in which case ErrClosed is returned once the queue is closed
+// As the slots are claimed before any of them is published, n must lie within the capacity the queue can reach
+// otherwise an error wrapping ErrInvalidSize is returned without claiming anything
+func (self *ZenQ[T]) ClaimBatch(n int) (first Sequence, err error) {
+	if n <= 0 {
+		return first, fmt.Errorf("%w: batch size %d is not positive", ErrInvalidSize, n)
+	} else if limit := self.batchLimit(); n > limit {
+		return first, fmt.Errorf("%w: batch size %d exceeds the capacity %d", ErrInvalidSize, n, limit)
+	}
+	if Load8(&self.globalState) != StateOpen {
+		return first, ErrClosed
+	}
+	queueClosedForWrites := false
+	r, writerIndex := self.claim(uint32(n))
+	// a resize never splits a range claimed via a single increment, hence all its indices are served by the same ring
+	r = r.resolve(writerIndex + 1)
+	for idx := uint32(1); idx <= uint32(n); idx++ {
+		// every index has to be gone through even after the queue turns out to be closed so that the readers skip
+		// the ones before the closing commit
+		if _, closed := self.claimAt(r, writerIndex+idx); closed {
+			queueClosedForWrites = true
+		}
+	}
+	if queueClosedForWrites {
+		// the closing commit never lies within a claimed range, hence the slots claimed already lie before it
+		// and are given up on as well so that their readers skip them
+		for idx := writerIndex + 1; idx != writerIndex+uint32(n)+1; idx++ {
+			if slot := r.slotAt(idx); slot.load() == newSlotState(idx, SlotBusy) {
+				slot.store(newSlotState(idx, SlotEmpty))
+				self.readyReaders(slot, idx)
+			}
+		}
+		return first, ErrClosed
+	}
+	return Sequence{writerIndex + 1, unsafe.Pointer(r)}, nil
+}
+
+// batchLimit returns the number of slots claimable via a single increment of the writer index without the claimer
+// waiting on its own slots, queues never shrink hence the limit never drops
+func (self *ZenQ[T]) batchLimit() int {
+	if limit := self.Cap(); limit > int(self.growthLimit) {
+		return limit
+	}
+	return int(self.growthLimit)
+}
+
+// Item returns a pointer to the item of a claimed slot which is valid until the slot is published
+func (self *ZenQ[T]) Item(seq Sequence) *T {
+	return &(*ring[T])(seq.ring).slotAt(seq.idx).item
+}
+
+// PublishBatch commits the items of n contiguous slots claimed via ClaimBatch() making them available to readers
+func (self *ZenQ[T]) PublishBatch(first Sequence, n int) {
+	for seq := first; seq.idx != first.idx+uint32(n); seq = seq.Add(1) {
+		self.Publish(seq)
+	}
+}
+
+// claimAt waits for the turn of the given writer index to come and leaves its slot busy for the claimer to fill
+// It returns the ring serving the index given the one loaded before the index was claimed
+func (self *ZenQ[T]) claimAt(r *ring[T], idx uint32) (_ *ring[T], queueClosedForWrites bool) {
+	var value T
+	r = r.resolve(idx)
+	_, queueClosedForWrites = self.awaitTurn(r, r.slotAt(idx), idx, false, value)
+	return r, queueClosedForWrites
+}
@@ -0,0 +1,146 @@
+package zenq_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+type claimedItem struct {
+	id   int
+	blob [512]int
+}
+
+func TestClaimPublish(t *testing.T) {
+	const writers, perWriter = 4, 5000
+	zq := zenq.New[claimedItem](16)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; {
+				if i%3 != 0 {
+					item, seq, closed := zq.Claim()
+					if closed {
+						t.Error("queue closed while claiming")
+						return
+					}
+					item.id, item.blob[511] = w*perWriter+i, w*perWriter+i
+					zq.Publish(seq)
+					i++
+					continue
+				}
+				n := 1 + i%5
+				if i+n > perWriter {
+					n = perWriter - i
+				}
+				first, err := zq.ClaimBatch(n)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				for k := 0; k < n; k++ {
+					item := zq.Item(first.Add(k))
+					item.id, item.blob[511] = w*perWriter+i+k, w*perWriter+i+k
+				}
+				zq.PublishBatch(first, n)
+				i += n
+			}
+		}(w)
+	}
+
+	// items of every writer are read in the order they were claimed in
+	last := make([]int, writers)
+	for i := range last {
+		last[i] = -1
+	}
+	for i := 0; i < writers*perWriter; i++ {
+		item, queueOpen := zq.Read()
+		if !queueOpen || item.blob[511] != item.id {
+			t.Fatalf("read %d, queue open %t", item.id, queueOpen)
+		}
+		if w := item.id / perWriter; item.id <= last[w] {
+			t.Fatalf("read %d after %d", item.id, last[w])
+		} else {
+			last[w] = item.id
+		}
+	}
+	wg.Wait()
+
+	zq.Close()
+	if _, _, closed := zq.Claim(); !closed {
+		t.Fatal("claimed a slot of a closed queue")
+	}
+	if _, err := zq.ClaimBatch(3); !errors.Is(err, zenq.ErrClosed) {
+		t.Fatalf("claimed a batch of a closed queue: %v", err)
+	}
+}
+
+func TestClaimBatchInvalidSize(t *testing.T) {
+	zq := zenq.New[int](8)
+	for _, n := range []int{0, -1, zq.Cap() + 1} {
+		if _, err := zq.ClaimBatch(n); !errors.Is(err, zenq.ErrInvalidSize) {
+			t.Fatalf("batch of %d: %v", n, err)
+		}
+	}
+
+	// nothing was claimed by the rejected batches
+	first, err := zq.ClaimBatch(zq.Cap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := 0; k < zq.Cap(); k++ {
+		*zq.Item(first.Add(k)) = k
+	}
+	zq.PublishBatch(first, zq.Cap())
+	for k := 0; k < zq.Cap(); k++ {
+		if item, _ := zq.Read(); item != k {
+			t.Fatalf("read %d, expected %d", item, k)
+		}
+	}
+
+	// batches are bounded by the size upto which a queue grows on its own
+	growing, _ := zenq.NewWithOptions[int](zenq.Options{Size: 4, GrowthLimit: 16})
+	if _, err := growing.ClaimBatch(17); !errors.Is(err, zenq.ErrInvalidSize) {
+		t.Fatalf("batch of 17: %v", err)
+	}
+	if first, err = growing.ClaimBatch(16); err != nil {
+		t.Fatal(err)
+	}
+	growing.PublishBatch(first, 16)
+	if size := growing.Size(); size != 16 {
+		t.Fatalf("size %d after publishing a batch of 16", size)
+	}
+}
+
+func TestClaimBatchClosedWhileWaiting(t *testing.T) {
+	zq := zenq.New[int](4)
+	zq.Write(1)
+	zq.Write(2)
+
+	claimed := make(chan error)
+	go func() {
+		_, err := zq.ClaimBatch(4)
+		claimed <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	zq.Close()
+	if err := <-claimed; !errors.Is(err, zenq.ErrClosed) {
+		t.Fatalf("claimed a batch across the closing of the queue: %v", err)
+	}
+
+	// items written beforehand are still read whereas the slots given up on are skipped
+	for i := 1; i <= 2; i++ {
+		if item, queueOpen := zq.Read(); !queueOpen || item != i {
+			t.Fatalf("read %d, queue open %t", item, queueOpen)
+		}
+	}
+	if _, queueOpen := zq.Read(); queueOpen {
+		t.Fatal("read a slot given up on")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !go1.21
+
+package zenq
+
+import "context"
+
+// watchContext calls fn once ctx is done unless stopped beforehand
+// A watcher goroutine is spawned as context.AfterFunc() is not available before Go 1.21
+func watchContext(ctx context.Context, fn func()) (stop func() bool) {
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			fn()
+		case <-stopped:
+		}
+	}()
+	return func() bool {
+		close(stopped)
+		return true
+	}
+}
@@ -0,0 +1,11 @@
+//go:build go1.21
+
+package zenq
+
+import "context"
+
+// watchContext calls fn once ctx is done unless stopped beforehand
+// No goroutine is spawned until ctx is done for the contexts of the standard library
+func watchContext(ctx context.Context, fn func()) (stop func() bool) {
+	return context.AfterFunc(ctx, fn)
+}
@@ -0,0 +1,141 @@
+package zenq
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Sentinel errors of the error based API, every error returned by it matches one of these via errors.Is()
+// ErrClosed is declared alongside the queue as the non-blocking operations return it as well
+var (
+	// ErrFull is returned by TrySend() in case the queue is full and by every send dropping its value as per DropNewest
+	ErrFull = errors.New("zenq: queue is full")
+	// ErrEmpty is returned by TryRecv() in case no value is available right away
+	ErrEmpty = errors.New("zenq: queue is empty")
+	// ErrTimeout is returned once a wait exceeds its timeout or the deadline of its context
+	ErrTimeout = errors.New("zenq: timed out")
+	// ErrCancelled is returned once the context of a wait is cancelled
+	ErrCancelled = errors.New("zenq: cancelled")
+)
+
+// causedError reports an error along with its cause, like a wait given up on as per its context or a queue closed with
+// an error, it matches both its sentinel and its cause
+type causedError struct {
+	sentinel error
+	cause    error
+}
+
+// Error implements the error interface
+func (self causedError) Error() string {
+	return self.sentinel.Error() + ": " + self.cause.Error()
+}
+
+// Is reports whether the sentinel of the error is the target
+func (self causedError) Is(target error) bool {
+	return target == self.sentinel
+}
+
+// Unwrap returns the cause of the error
+func (self causedError) Unwrap() error {
+	return self.cause
+}
+
+// contextError returns the error for a wait given up on as per ctx
+// which is ErrTimeout in case its deadline exceeded and ErrCancelled otherwise
+func contextError(ctx context.Context) error {
+	if err := ctx.Err(); err != context.DeadlineExceeded {
+		return causedError{ErrCancelled, err}
+	}
+	return causedError{ErrTimeout, context.DeadlineExceeded}
+}
+
+// Send writes a value to the queue just like Write()
+// It returns ErrClosed if the queue is closed for writes and ErrFull if the value was dropped as per DropNewest
+func (self *ZenQ[T]) Send(value T) error {
+	return self.write(nil, value)
+}
+
+// TrySend writes a value to the queue only if it can be done without waiting just like TryWrite()
+// It returns ErrFull if the queue is full and ErrClosed if the queue is closed for writes
+func (self *ZenQ[T]) TrySend(value T) error {
+	if ok, err := self.TryWrite(value); err != nil {
+		return err
+	} else if !ok {
+		return ErrFull
+	}
+	return nil
+}
+
+// SendContext writes a value to the queue just like Send() but gives up waiting for a free slot once ctx is done
+// in which case the value is not written and the error matches ErrTimeout or ErrCancelled along with ctx.Err()
+func (self *ZenQ[T]) SendContext(ctx context.Context, value T) error {
+	if ctx.Err() != nil {
+		return contextError(ctx)
+	}
+	if err := self.write(ctx, value); err != ErrCancelled {
+		return err
+	}
+	return contextError(ctx)
+}
+
+// SendTimeout writes a value to the queue just like Send() but gives up waiting for a free slot after the timeout
+// in which case the value is not written and ErrTimeout is returned
+func (self *ZenQ[T]) SendTimeout(value T, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return self.SendContext(ctx, value)
+}
+
+// Recv reads a value from the queue just like Read()
+// It returns ErrClosed once the queue is closed and fully drained or an error matching both ErrClosed and the cause
+// in case the queue was closed via CloseWithError()
+func (self *ZenQ[T]) Recv() (T, error) {
+	data, queueOpen := self.Read()
+	if !queueOpen {
+		return data, self.closedError()
+	}
+	return data, nil
+}
+
+// TryRecv reads a value from the queue only if one is available right away just like TryRead()
+// It returns ErrEmpty if the queue is empty and an error matching ErrClosed once the queue is closed and fully drained
+func (self *ZenQ[T]) TryRecv() (T, error) {
+	data, ok, err := self.TryRead()
+	if err == nil && !ok {
+		err = ErrEmpty
+	}
+	return data, err
+}
+
+// RecvContext reads a value from the queue just like Recv() but gives up waiting for a value once ctx is done
+// in which case the error matches ErrTimeout or ErrCancelled along with ctx.Err()
+func (self *ZenQ[T]) RecvContext(ctx context.Context) (data T, err error) {
+	if ctx.Err() != nil {
+		return data, contextError(ctx)
+	}
+	data, queueOpen, cancelled := self.read(ctx)
+	if cancelled {
+		err = contextError(ctx)
+	} else if !queueOpen {
+		err = self.closedError()
+	}
+	return
+}
+
+// RecvTimeout reads a value from the queue just like Recv() but gives up waiting for a value after the timeout
+// in which case ErrTimeout is returned
+func (self *ZenQ[T]) RecvTimeout(timeout time.Duration) (T, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return self.RecvContext(ctx)
+}
+
+// Shutdown closes the queue for writes just like Close()
+// It returns ErrClosed if the queue was already closed for writes
+func (self *ZenQ[T]) Shutdown() error {
+	if self.Close() {
+		return ErrClosed
+	}
+	return nil
+}
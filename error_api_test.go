@@ -0,0 +1,86 @@
+package zenq_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+func TestSendRecv(t *testing.T) {
+	zq := zenq.New[int](2)
+	if _, err := zq.TryRecv(); err != zenq.ErrEmpty {
+		t.Fatalf("received from an empty queue: %v", err)
+	}
+	if err := zq.Send(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := zq.TrySend(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := zq.TrySend(3); err != zenq.ErrFull {
+		t.Fatalf("sent to a full queue: %v", err)
+	}
+
+	// timeouts and cancellations wrap the context errors they stem from
+	start := time.Now()
+	err := zq.SendTimeout(3, 20*time.Millisecond)
+	if !errors.Is(err, zenq.ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, zenq.ErrCancelled) {
+		t.Fatalf("sent to a full queue: %v", err)
+	} else if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("timed out after %v", elapsed)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if err := zq.SendContext(ctx, 3); !errors.Is(err, zenq.ErrCancelled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("sent to a full queue: %v", err)
+	}
+	if err := zq.SendContext(ctx, 3); !errors.Is(err, zenq.ErrCancelled) {
+		t.Fatalf("sent with a cancelled context: %v", err)
+	}
+
+	if item, err := zq.Recv(); item != 1 || err != nil {
+		t.Fatalf("received %d: %v", item, err)
+	}
+	if item, err := zq.RecvTimeout(time.Second); item != 2 || err != nil {
+		t.Fatalf("received %d: %v", item, err)
+	}
+	if _, err := zq.RecvTimeout(10 * time.Millisecond); !errors.Is(err, zenq.ErrTimeout) {
+		t.Fatalf("received from an empty queue: %v", err)
+	}
+
+	zq.Send(5)
+	if err := zq.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zq.Shutdown(); err != zenq.ErrClosed {
+		t.Fatalf("shut down twice: %v", err)
+	}
+	if err := zq.Send(1); err != zenq.ErrClosed {
+		t.Fatalf("sent to a closed queue: %v", err)
+	}
+	if err := zq.TrySend(1); err != zenq.ErrClosed {
+		t.Fatalf("sent to a closed queue: %v", err)
+	}
+	// the items sent beforehand are still received
+	if item, err := zq.RecvContext(context.Background()); item != 5 || err != nil {
+		t.Fatalf("received %d: %v", item, err)
+	}
+	if _, err := zq.Recv(); err != zenq.ErrClosed {
+		t.Fatalf("received from a drained closed queue: %v", err)
+	}
+	if _, err := zq.TryRecv(); err != zenq.ErrClosed {
+		t.Fatalf("received from a drained closed queue: %v", err)
+	}
+
+	dropping, _ := zenq.NewWithOptions[int](zenq.Options{Size: 1, FullPolicy: zenq.DropNewest})
+	dropping.Send(1)
+	if err := dropping.Send(2); err != zenq.ErrFull {
+		t.Fatalf("value dropped: %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package zenq
+
+// ParkSelector parks the calling goroutine just like a selector enqueued via EnqueueSelector() with its bare thread
+// pointer does, it returns once a stream calls it
+func ParkSelector() {
+	mcall(fast_park)
+}
+
+// ParkWithValue parks the calling goroutine on tp via Park() with the given value to be handed over to Ready()
+func ParkWithValue[T any](tp *ThreadParker[T], value T) {
+	tp.Park(&parkSpot[T]{waitSpot: waitSpot{threadPtr: GetG()}, value: value})
+	mcall(fast_park)
+}
+
+// LinkedSpots returns the number of spots linked into the parkers of the slots of zq, including the ones whose
+// goroutines are not waiting anymore, zq must not be operated on meanwhile
+func LinkedSpots[T any](zq *ZenQ[T]) (n int) {
+	for r := zq.readRing.Load(); r != nil; r = r.next.Load() {
+		r.each(func(slot *slot[T]) {
+			for _, tp := range []*ThreadParker[T]{&slot.readParker, &slot.writeParker} {
+				if head := tp.head.Load(); head != nil {
+					for spot := head.next.Load(); spot != nil; spot = spot.next.Load() {
+						n++
+					}
+				}
+			}
+		})
+	}
+	return
+}
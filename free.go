@@ -0,0 +1,57 @@
+package zenq
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// Free closes the queue for good, discards the items still in it and stops its auxillary thread so that the queue
+// is garbage collected as soon as it is unreachable
+// Every goroutine waiting on the queue is called right away just like in Close() and every selector waiting on it
+// receives the result of a closed queue, the queue must not be used afterwards as it cannot be reopened anymore
+// Queues which turn unreachable without being freed are freed by a finalizer instead, it is safe to call Free()
+// multiple times
+func (self *ZenQ[T]) Free() {
+	if state := self.selectionState.Swap(SelectionFreed); state == SelectionOpen || state == SelectionAwaiting {
+		// the auxillary thread is parked without referring to the queue, hence it exits once called
+		safe_ready(self.auxThread, self.waitStrategy)
+	}
+	self.Close()
+	// the readers still to claim their indices find nothing left, whereas the ones parked beyond the closing commit
+	// find the queue closed once called
+	self.mutex.Lock()
+	Store8(&self.globalState, StateFullyClosed)
+	self.mutex.Unlock()
+	for r := self.readRing.Load(); r != nil; r = r.next.Load() {
+		r.each(self.readyAllReaders)
+	}
+	// no auxillary thread is going to serve the selectors waiting on the queue anymore
+	self.serveClosed()
+	runtime.SetFinalizer(self, nil)
+}
+
+// serveClosed hands the result of a closed queue over to every selector waiting on the queue
+func (self *ZenQ[T]) serveClosed() {
+	for {
+		waiter, threadPtr := self.selectors.acquire()
+		if threadPtr == nil {
+			break
+		}
+		waiter.handOver(self, self.closedSelection(), threadPtr, self.waitStrategy)
+	}
+	for {
+		threadPtr, dataOut, spot := self.waitList.dequeue()
+		if threadPtr == nil {
+			return
+		}
+		if selThread := atomic.SwapPointer(threadPtr, nil); selThread != nil {
+			*dataOut = self.closedSelection()
+			readySelector(threadPtr, selThread, self.waitStrategy, spot)
+		}
+	}
+}
+
+// Destroy frees the queue just like Free()
+func (self *ZenQ[T]) Destroy() {
+	self.Free()
+}
@@ -0,0 +1,197 @@
+package zenq_test
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+func TestSignalledQueueIsFinalized(t *testing.T) {
+	const numQueues = 20
+	goroutines := settledGoroutines()
+
+	func() {
+		for i := 0; i < numQueues; i++ {
+			// the auxiliary goroutine finds nothing to read ahead
+			zq := zenq.New[int](4)
+			zq.Signal()
+		}
+	}()
+
+	if !awaitGoroutines(goroutines) {
+		t.Fatalf("%d goroutines left behind by %d unreachable queues", runtime.NumGoroutine()-goroutines, numQueues)
+	}
+}
+
+func TestSignalledQueueReadsAheadOnCommit(t *testing.T) {
+	zq := zenq.New[int](4)
+	zq.Signal()
+	time.Sleep(10 * time.Millisecond)
+	if n := zq.Signal(); n != 1 {
+		t.Fatalf("signalled %d selectors while awaiting a commit", n)
+	}
+
+	for i := 0; i < 100; i++ {
+		go func(i int) {
+			time.Sleep(time.Millisecond)
+			zq.Write(i)
+		}(i)
+		if index, value, ok := zenq.SelectIndex(zq); index != 0 || value != i || !ok {
+			t.Fatalf("selected %v from %d, ok %t", value, index, ok)
+		}
+	}
+
+	// a queue freed while awaiting a commit is selected as closed instead of being waited on
+	zq.Free()
+	if index, value, ok := zenq.SelectIndex(zq); index != 0 || value != nil || ok {
+		t.Fatalf("selected %v from %d after Free(), ok %t", value, index, ok)
+	}
+}
+
+// settledGoroutines waits for the goroutines of earlier tests to wind down and returns the number left
+func settledGoroutines() int {
+	n := runtime.NumGoroutine()
+	for same := 0; same < 5; {
+		runtime.GC()
+		time.Sleep(5 * time.Millisecond)
+		if m := runtime.NumGoroutine(); m == n {
+			same++
+		} else {
+			n, same = m, 0
+		}
+	}
+	return n
+}
+
+// awaitGoroutines waits for the number of goroutines to drop to n and reports whether it did
+func awaitGoroutines(n int) bool {
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		runtime.GC()
+		if runtime.NumGoroutine() <= n {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFreeStopsAuxiliaryGoroutine(t *testing.T) {
+	const numQueues = 100
+	goroutines := settledGoroutines()
+	zqs := make([]*zenq.ZenQ[int], numQueues)
+	for i := range zqs {
+		zqs[i] = zenq.New[int](4)
+	}
+	if n := runtime.NumGoroutine(); n < goroutines+numQueues {
+		t.Fatalf("%d goroutines for %d queues", n-goroutines, numQueues)
+	}
+	for i, zq := range zqs {
+		zq.Write(i)
+		if i%2 == 0 {
+			if data := zenq.Select(zq); data != i {
+				t.Fatalf("selected %v", data)
+			}
+		}
+		zq.Free()
+		zq.Destroy()
+		// a freed queue is closed for good
+		if !zq.IsClosed() {
+			t.Fatal("freed queue open")
+		}
+		if _, queueOpen := zq.Read(); queueOpen {
+			t.Fatal("read from a freed queue")
+		}
+		if generation := zq.Reopen(); generation != 0 || !zq.IsClosed() {
+			t.Fatalf("reopened a freed queue as generation %d", generation)
+		}
+	}
+	if !awaitGoroutines(goroutines) {
+		t.Fatalf("%d goroutines left behind by %d freed queues", runtime.NumGoroutine()-goroutines, numQueues)
+	}
+}
+
+func TestUnreachableQueuesAreFreed(t *testing.T) {
+	const numQueues = 100
+	goroutines := settledGoroutines()
+	func() {
+		for i := 0; i < numQueues; i++ {
+			zq := zenq.New[int](4)
+			zq.Write(i)
+			if i%3 == 0 {
+				zenq.Select(zq)
+			}
+		}
+	}()
+	if !awaitGoroutines(goroutines) {
+		t.Fatalf("%d goroutines left behind by %d unreachable queues", runtime.NumGoroutine()-goroutines, numQueues)
+	}
+}
+
+func TestFreeWakesParkedGoroutines(t *testing.T) {
+	zq, _ := zenq.NewWithOptions[int](zenq.Options{Size: 2, WaitStrategy: zenq.Blocking{}})
+	other := zenq.New[int](2)
+	defer other.Free()
+
+	woken := make(chan any, 3)
+	for i := 0; i < 2; i++ {
+		go func() {
+			if item, queueOpen := zq.Read(); queueOpen {
+				woken <- item
+			} else {
+				woken <- nil
+			}
+		}()
+	}
+	go func() { woken <- zenq.Select(zq, other) }()
+	time.Sleep(20 * time.Millisecond)
+	zq.Free()
+	for i := 0; i < 3; i++ {
+		select {
+		case data := <-woken:
+			if data != nil {
+				t.Fatalf("got %v from a freed queue", data)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("reader or selector still waiting")
+		}
+	}
+	if data := zenq.Select(zq); data != nil {
+		t.Fatalf("selected %v from a freed queue", data)
+	}
+
+	full, _ := zenq.NewWithOptions[int](zenq.Options{Size: 1, WaitStrategy: zenq.Blocking{}})
+	full.Write(1)
+	failedWrite := make(chan bool)
+	go func() { failedWrite <- full.Write(2) }()
+	time.Sleep(20 * time.Millisecond)
+	full.Free()
+	select {
+	case failed := <-failedWrite:
+		if !failed {
+			t.Fatal("wrote to a freed queue")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("writer still waiting")
+	}
+}
+
+func TestFreeWhileSelecting(t *testing.T) {
+	for i := 0; i < 300; i++ {
+		zq := zenq.New[int](2)
+		selected := make(chan any)
+		go func() { selected <- zenq.Select(zq) }()
+		if i%2 == 0 {
+			runtime.Gosched()
+		}
+		zq.Free()
+		select {
+		case data := <-selected:
+			if data != nil {
+				t.Fatalf("selected %v from a freed queue", data)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("selection %d still waiting", i)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package zenq
+
+// Dropped returns the number of items dropped so far as per the full policy of the queue
+// i.e. the values dropped by DropNewest, the items evicted by DropOldest and the items overwritten by Overwrite
+func (self *ZenQ[T]) Dropped() uint64 {
+	return self.dropped.Load()
+}
+
+// writeOrDrop writes a value to the queue without waiting for a free slot as per the DropNewest and DropOldest policies
+// i.e. the value is either dropped or the item at the head of the queue is evicted for as long as the queue is full
+func (self *ZenQ[T]) writeOrDrop(value T) (written bool, queueClosedForWrites bool) {
+	for attempt := uint32(0); ; attempt++ {
+		if written, queueClosedForWrites = self.tryWrite(value); written || queueClosedForWrites {
+			return
+		} else if self.fullPolicy == DropNewest {
+			self.dropped.Add(1)
+			return
+		}
+		if _, evicted, queueClosed := self.tryRead(); queueClosed {
+			return false, true
+		} else if evicted {
+			self.dropped.Add(1)
+		} else {
+			// the head is being read or written at the moment
+			backoff(self.waitStrategy, attempt)
+		}
+	}
+}
+
+// overwrite claims the item at the head of the queue for the writer of the given index in case the slot of the index
+// holds that very item from the previous lap, the writer then takes over the slot in place as per the Overwrite policy
+// instead of waiting for the item to be read
+func (self *ZenQ[T]) overwrite(r *ring[T], slot *slot[T], idx uint32) bool {
+	head := idx - r.indexMask - 1
+	// claiming the reader index of the item makes sure no reader ever gets to read it
+	if slot.load() != newSlotState(head, SlotCommitted) || !self.readerIndex.CompareAndSwap(head-1, head) {
+		return false
+	}
+	self.dropped.Add(1)
+	return true
+}
@@ -0,0 +1,214 @@
+package zenq_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+func TestDropNewest(t *testing.T) {
+	zq, _ := zenq.NewWithOptions[int](zenq.Options{Size: 4, FullPolicy: zenq.DropNewest})
+	for i := 0; i < 10; i++ {
+		zq.Write(i)
+	}
+	if n, _ := zq.WriteBatch([]int{10, 11}); n != 0 {
+		t.Fatalf("wrote %d values to a full queue", n)
+	}
+	if _, err := zq.WriteContext(context.Background(), 12); err != nil {
+		t.Fatal(err)
+	}
+	if dropped := zq.Dropped(); dropped != 9 {
+		t.Fatalf("dropped %d values, expected 9", dropped)
+	}
+	for i := 0; i < 4; i++ {
+		if item, _ := zq.Read(); item != i {
+			t.Fatalf("read %d, expected %d", item, i)
+		}
+	}
+}
+
+func testKeepsLatest(t *testing.T, policy zenq.FullPolicy) {
+	zq, _ := zenq.NewWithOptions[int](zenq.Options{Size: 4, FullPolicy: policy})
+	for i := 0; i < 10; i++ {
+		zq.Write(i)
+	}
+	if n, _ := zq.WriteBatch([]int{10, 11}); n != 2 {
+		t.Fatalf("wrote %d values out of a batch of 2", n)
+	}
+	// claims overwrite the oldest items just like writes in case of Overwrite
+	if policy == zenq.Overwrite {
+		item, seq, _ := zq.Claim()
+		*item = 12
+		zq.Publish(seq)
+	} else {
+		zq.Write(12)
+	}
+	if dropped := zq.Dropped(); dropped != 9 {
+		t.Fatalf("dropped %d items, expected 9", dropped)
+	}
+	for i := 9; i <= 12; i++ {
+		if item, _ := zq.Read(); item != i {
+			t.Fatalf("read %d, expected %d", item, i)
+		}
+	}
+}
+
+func TestDropOldest(t *testing.T) {
+	testKeepsLatest(t, zenq.DropOldest)
+}
+
+func TestOverwrite(t *testing.T) {
+	testKeepsLatest(t, zenq.Overwrite)
+}
+
+func TestFullPolicyOptions(t *testing.T) {
+	if _, err := zenq.NewWithOptions[int](zenq.Options{Size: 5, Rounding: zenq.ExactCapacity, FullPolicy: zenq.Overwrite}); !errors.Is(err, zenq.ErrInvalidOptions) {
+		t.Fatalf("combined Overwrite with ExactCapacity: %v", err)
+	}
+
+	// items are evicted at the exact capacity rather than at the size of the underlying ring
+	zq, err := zenq.NewWithOptions[int](zenq.Options{Size: 5, Rounding: zenq.ExactCapacity, FullPolicy: zenq.DropOldest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 9; i++ {
+		zq.Write(i)
+	}
+	for i := 4; i < 9; i++ {
+		if item, _ := zq.Read(); item != i {
+			t.Fatalf("read %d, expected %d", item, i)
+		}
+	}
+}
+
+func TestFullPoliciesWhileContending(t *testing.T) {
+	for _, policy := range []zenq.FullPolicy{zenq.DropNewest, zenq.DropOldest, zenq.Overwrite} {
+		const writers, perWriter = 4, 20000
+		zq, _ := zenq.NewWithOptions[int](zenq.Options{Size: 8, FullPolicy: policy})
+
+		last := make([]int, writers)
+		for i := range last {
+			last[i] = -1
+		}
+		var read atomic.Int64
+		readerDone := make(chan struct{})
+		go func() {
+			defer close(readerDone)
+			for {
+				item, queueOpen := zq.Read()
+				if !queueOpen {
+					return
+				}
+				// the items kept are still read in the order of their writers
+				if w := item / perWriter; item <= last[w] {
+					t.Errorf("policy %d: read %d after %d", policy, item, last[w])
+				} else {
+					last[w] = item
+				}
+				if read.Add(1)%64 == 0 {
+					time.Sleep(time.Microsecond)
+				}
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < perWriter; i++ {
+					if i%10 == 9 {
+						zq.WriteBatch([]int{w*perWriter + i})
+					} else {
+						zq.Write(w*perWriter + i)
+					}
+				}
+			}(w)
+		}
+		written := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(written)
+		}()
+		select {
+		case <-written:
+		case <-time.After(30 * time.Second):
+			t.Fatalf("policy %d: writers blocked", policy)
+		}
+		zq.Close()
+		<-readerDone
+
+		if n := read.Load() + int64(zq.Dropped()); n != writers*perWriter {
+			t.Fatalf("policy %d: read %d and dropped %d out of %d", policy, read.Load(), zq.Dropped(), writers*perWriter)
+		}
+	}
+}
+
+func TestFullPoliciesWithManyReaders(t *testing.T) {
+	for _, policy := range []zenq.FullPolicy{zenq.DropOldest, zenq.Overwrite} {
+		const writers, readers, perWriter = 4, 3, 20000
+		zq, _ := zenq.NewWithOptions[int](zenq.Options{Size: 4, FullPolicy: policy})
+
+		var seen sync.Map
+		var read atomic.Int64
+		var rg sync.WaitGroup
+		for r := 0; r < readers; r++ {
+			rg.Add(1)
+			go func(r int) {
+				defer rg.Done()
+				batch := make([]int, 3)
+				for i := 0; ; i++ {
+					var items []int
+					// readers racing the evictions read via every kind of read
+					if i%2 == 0 {
+						item, queueOpen := zq.Read()
+						if !queueOpen {
+							return
+						}
+						items = []int{item}
+					} else if r == 0 {
+						if item, ok, _ := zq.TryRead(); ok {
+							items = []int{item}
+						} else if zq.IsClosed() {
+							return
+						}
+					} else {
+						n, queueOpen := zq.ReadBatch(batch)
+						if !queueOpen {
+							return
+						}
+						items = batch[:n]
+					}
+					for _, item := range items {
+						if _, twice := seen.LoadOrStore(item, true); twice {
+							t.Errorf("policy %d: read %d twice", policy, item)
+						}
+						read.Add(1)
+					}
+				}
+			}(r)
+		}
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < perWriter; i++ {
+					zq.Write(w*perWriter + i)
+				}
+			}(w)
+		}
+		wg.Wait()
+		zq.Close()
+		rg.Wait()
+
+		if n := read.Load() + int64(zq.Dropped()); n != writers*perWriter {
+			t.Fatalf("policy %d: read %d and dropped %d out of %d", policy, read.Load(), zq.Dropped(), writers*perWriter)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package zenq
+
+// Len returns the number of items in the queue
+// It is exact while no reads or writes are underway, otherwise it is approximate as the reader and writer indices are
+// loaded one after the other and the values being committed are counted as items already
+// It never counts the writers waiting for room, hence it never exceeds the capacity unless the queue was resized
+// in which case the older rings still hold their items on top of the capacity of the current one
+func (self *ZenQ[T]) Len() int {
+	r := self.readRing.Load()
+	readerIndex, writerIndex := self.readerIndex.Load(), self.writerIndex.Load()
+	if Load8(&self.globalState) != StateOpen {
+		// neither the closing commit nor the indices claimed beyond it hold any items
+		writerIndex = self.closeIndex.Load() - 1
+	}
+	var items int
+	// the indices pending in every ring from the read ring upto the write ring hold upto its capacity of items
+	for ; r != nil; r = r.next.Load() {
+		from, to := readerIndex, writerIndex
+		if int32(r.start-1-from) > 0 {
+			from = r.start - 1
+		}
+		if r.next.Load() != nil && int32(to-r.end+1) > 0 {
+			to = r.end - 1
+		}
+		if pending := int32(to - from); pending > int32(r.capacity) {
+			items += int(r.capacity)
+		} else if pending > 0 {
+			items += int(pending)
+		}
+	}
+	return items
+}
+
+// Cap returns the capacity of the queue i.e. the maximum number of items it holds at a time which is exact
+// Queues which grow report the capacity of their current ring, hence Cap() changes once they grow
+func (self *ZenQ[T]) Cap() int {
+	return int(self.writeRing.Load().capacity)
+}
+
+// IsFull returns whether a write has to wait for a reader at the moment or else drop an item as per the full policy
+// i.e. whether TryWrite() fails for want of room, queues which grow on their own are only full once they cannot grow
+// It is exact as of the moment it was checked which might be outdated by concurrent reads and writes right away
+func (self *ZenQ[T]) IsFull() bool {
+	for {
+		r := self.writeRing.Load()
+		writerIndex := self.writerIndex.Load()
+		current := r.resolve(writerIndex + 1)
+		if vacant, stale := current.vacant(writerIndex, 1); !stale {
+			return !vacant && self.growth(current, 1) == 0
+		}
+		// the index was claimed meanwhile
+	}
+}
+
+// IsEmpty returns whether a read has to wait for a writer at the moment i.e. whether TryRead() fails
+// It is exact as of the moment it was checked which might be outdated by concurrent reads and writes right away
+// A value being committed is not readable yet, hence the queue might be empty even though Len() is positive
+func (self *ZenQ[T]) IsEmpty() bool {
+	for {
+		r := self.readRing.Load()
+		readerIndex := self.readerIndex.Load()
+		state := r.resolve(readerIndex + 1).slotAt(readerIndex + 1).load()
+		if turn := state.turn(); turn == readerIndex+1 && state.phase() == SlotCommitted {
+			return false
+		} else if int32(turn-readerIndex-1) <= 0 {
+			return true
+		}
+		// the index was claimed meanwhile
+	}
+}
+
+// State returns the global state of the queue, one of StateOpen, StateClosedForWrites and StateFullyClosed
+// which is exact
+func (self *ZenQ[T]) State() uint8 {
+	return Load8(&self.globalState)
+}
@@ -0,0 +1,166 @@
+package zenq_test
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+func TestLen(t *testing.T) {
+	zq := zenq.New[int](8)
+	if zq.Len() != 0 || !zq.IsEmpty() || zq.IsFull() || zq.Cap() != 8 || zq.State() != zenq.StateOpen {
+		t.Fatalf("new queue of length %d, state %d", zq.Len(), zq.State())
+	}
+	for i := 1; i <= 8; i++ {
+		zq.Write(i)
+		if zq.Len() != i || zq.Size() != uint32(i) || zq.IsEmpty() {
+			t.Fatalf("length %d and size %d after %d writes", zq.Len(), zq.Size(), i)
+		}
+	}
+	if !zq.IsFull() {
+		t.Fatal("queue not full")
+	}
+
+	// writers waiting for room are not counted
+	written := make(chan struct{})
+	go func() {
+		zq.Write(9)
+		close(written)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if zq.Len() != 8 || !zq.IsFull() {
+		t.Fatalf("length %d with a writer waiting", zq.Len())
+	}
+	zq.Read()
+	<-written
+	if zq.Len() != 8 {
+		t.Fatalf("length %d", zq.Len())
+	}
+	for i := 0; i < 8; i++ {
+		zq.Read()
+	}
+	if zq.Len() != 0 || !zq.IsEmpty() {
+		t.Fatalf("length %d once drained", zq.Len())
+	}
+
+	// and neither are readers waiting for items
+	read := make(chan struct{})
+	go func() {
+		zq.Read()
+		close(read)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if zq.Len() != 0 {
+		t.Fatalf("length %d with a reader waiting", zq.Len())
+	}
+	zq.Write(1)
+	zq.Write(2)
+	<-read
+	zq.Close()
+	if zq.State() != zenq.StateClosedForWrites || zq.Len() != 1 {
+		t.Fatalf("length %d, state %d once closed", zq.Len(), zq.State())
+	}
+	zq.Read()
+	zq.Read()
+	if zq.State() != zenq.StateFullyClosed || zq.Len() != 0 || !zq.IsEmpty() {
+		t.Fatalf("length %d, state %d once drained", zq.Len(), zq.State())
+	}
+}
+
+func TestLenExactAndResized(t *testing.T) {
+	exact, _ := zenq.NewWithOptions[int](zenq.Options{Size: 5, Rounding: zenq.ExactCapacity})
+	for i := 0; i < 5; i++ {
+		exact.Write(i)
+	}
+	if exact.Len() != 5 || !exact.IsFull() || exact.Cap() != 5 {
+		t.Fatalf("length %d and capacity %d, full %t", exact.Len(), exact.Cap(), exact.IsFull())
+	}
+
+	// the items of retired rings are counted on top of the current one
+	resized := zenq.New[int](4)
+	for i := 0; i < 4; i++ {
+		resized.Write(i)
+	}
+	resized.Resize(8)
+	if resized.IsFull() || resized.Cap() != 8 {
+		t.Fatal("queue full once resized")
+	}
+	for i := 0; i < 3; i++ {
+		resized.Write(i)
+	}
+	if resized.Len() != 7 {
+		t.Fatalf("length %d", resized.Len())
+	}
+	resized.Read()
+	if resized.Len() != 6 {
+		t.Fatalf("length %d", resized.Len())
+	}
+
+	// queues able to grow are full only at their growth limit
+	growing, _ := zenq.NewWithOptions[int](zenq.Options{Size: 2, GrowthLimit: 4})
+	for i := 0; i < 6; i++ {
+		if growing.IsFull() {
+			t.Fatalf("queue full after %d writes", i)
+		}
+		growing.Write(i)
+	}
+	if !growing.IsFull() || growing.Len() != 6 {
+		t.Fatalf("length %d, full %t", growing.Len(), growing.IsFull())
+	}
+
+	// and unbounded ones never are
+	unbounded := zenq.NewUnbounded[int](2)
+	for i := 0; i < 100; i++ {
+		unbounded.Write(i)
+	}
+	if unbounded.IsFull() || unbounded.Len() != 100 {
+		t.Fatalf("length %d, full %t", unbounded.Len(), unbounded.IsFull())
+	}
+}
+
+func TestLenWhileContending(t *testing.T) {
+	const pairs, perGoroutine = 4, 20000
+	zq := zenq.New[int](16)
+
+	var wg sync.WaitGroup
+	for p := 0; p < pairs; p++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				zq.Write(i)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				zq.Read()
+			}
+		}()
+	}
+	stop := make(chan struct{})
+	checked := make(chan struct{})
+	go func() {
+		defer close(checked)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			runtime.Gosched()
+			if n := zq.Len(); n < 0 || n > zq.Cap() {
+				t.Errorf("length %d", n)
+			}
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	<-checked
+	if n := zq.Len(); n != 0 {
+		t.Fatalf("length %d once drained", n)
+	}
+}
@@ -2,6 +2,7 @@ package zenq
 
 import (
 	"runtime"
+	"sync/atomic"
 	"unsafe"
 	_ "unsafe"
 
@@ -167,29 +168,41 @@ func fast_park(gp unsafe.Pointer) {
 	schedule()
 }
 
+// custom parking function which flags the goroutine as parked once it is
+// A goroutine might be found waiting for other reasons as well, like a GC assist or a suspension by the GC,
+// hence wakers which are unable to tell whether the goroutine is parked yet wait for the flag instead
+// Unlike fast_park() the status is changed before dropping the goroutine just like park_m() does,
+// so that the GC never finds it running without an M
+func flagged_park(gp unsafe.Pointer, parked *atomic.Bool) {
+	casgstatus(gp, _Grunning, _Gwaiting)
+	dropg()
+	parked.Store(true)
+	schedule()
+}
+
 // whether the system has multiple cores or a single core
 var multicore = runtime.NumCPU() > 1
 
-// call ready after ensuring the goroutine is parked
-func safe_ready(gp unsafe.Pointer) {
-	// for better microprocessor branch prediction
-	if multicore {
-		for Readgstatus(gp)&^_Gscan != _Gwaiting {
-			spin(20)
-		}
-	} else {
-		for Readgstatus(gp)&^_Gscan != _Gwaiting {
-			mcall(gosched_m)
-		}
+// call ready after ensuring the goroutine is parked, waiting meanwhile as per the given strategy
+func safe_ready(gp unsafe.Pointer, ws WaitStrategy) {
+	for attempt := uint32(0); Readgstatus(gp)&^_Gscan != _Gwaiting; attempt++ {
+		backoff(ws, attempt)
+	}
+	goready(gp, 1)
+}
+
+// call ready once the goroutine is flagged as parked by flagged_park(), waiting meanwhile as per the given strategy
+func flagged_ready(gp unsafe.Pointer, parked *atomic.Bool, ws WaitStrategy) {
+	for attempt := uint32(0); !parked.Load(); attempt++ {
+		backoff(ws, attempt)
 	}
 	goready(gp, 1)
 }
 
-// simple wait
-func wait() {
-	if multicore {
-		spin(20)
-	} else {
+// backoff waits as per the given strategy for an event which does not notify the waiting goroutine
+// hence it yields in case the strategy opts for parking
+func backoff(ws WaitStrategy, attempt uint32) {
+	if ws.Wait(attempt) {
 		mcall(gosched_m)
 	}
 }
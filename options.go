@@ -0,0 +1,148 @@
+package zenq
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"unsafe"
+
+	"github.com/alphadose/zenq/v2/constants"
+)
+
+// ErrInvalidOptions is returned by NewWithOptions() wrapped along with the reason the options were rejected
+var ErrInvalidOptions = errors.New("zenq: invalid options")
+
+// Rounding decides how the requested size of a queue is turned into its capacity
+type Rounding uint8
+
+// Rounding enums
+const (
+	// Round the size up to the next greater power of 2
+	RoundUpToPowerOf2 Rounding = iota
+	// Reject sizes which are not a power of 2 instead of silently rounding them
+	RequirePowerOf2
+	// Keep the size as the exact capacity, writers block or fail at this limit
+	// even though the underlying ring is still rounded up to a power of 2
+	ExactCapacity
+)
+
+// FullPolicy decides what a write does when the queue is full
+type FullPolicy uint8
+
+// FullPolicy enums
+const (
+	// Block the writer until a slot is freed by a reader
+	BlockWhenFull FullPolicy = iota
+	// Append an overflow segment to the queue for the writer to proceed right away, hence the queue is unbounded
+	// Readers drain the segments in order and every drained segment is garbage collected
+	// Writers only block once MaxQueueSize items are pending
+	OverflowWhenFull
+	// Drop the value being written and return right away
+	DropNewest
+	// Evict the item at the head of the queue to make room for the value being written
+	DropOldest
+	// Overwrite the oldest item in place as per ring semantics so that the queue retains the latest items only
+	// Writers never wait for readers, only for the writers of the items they overwrite
+	Overwrite
+)
+
+// Options configure a queue created via NewWithOptions(), the zero value of every field except Size picks its default
+type Options struct {
+	// Size is the requested capacity of the queue, turned into the actual capacity as per Rounding
+	Size uint32
+	// Rounding is RoundUpToPowerOf2 by default
+	Rounding Rounding
+	// WaitStrategy is Blocking with DefaultSpinBudget spins by default
+	WaitStrategy WaitStrategy
+	// FullPolicy is BlockWhenFull by default, OverflowWhenFull cannot be combined with ExactCapacity or GrowthLimit
+	// and Overwrite cannot be combined with ExactCapacity
+	// The policies only apply to Write(), WriteContext() and WriteBatch(), claims and non-blocking writes never drop
+	// anything except that Claim() and ClaimBatch() overwrite the oldest items as well in case of Overwrite
+	FullPolicy FullPolicy
+	// PadSlots pads every slot to a multiple of the cache line size so that adjacent slots do not share a cache line
+	// which prevents false sharing among them at the cost of memory
+	PadSlots bool
+	// NoSelect skips starting the auxiliary goroutine needed by Select(), hence such a queue is skipped just like a nil
+	// stream by Select(), SelectIndex(), TrySelect(), SelectContext() and Selectors, whereas SelectOf() and
+	// SelectWrite() rely on no auxiliary goroutine and select it as usual
+	// It saves a goroutine per queue for queues which are only ever read from directly
+	NoSelect bool
+	// GrowthLimit lets a full queue double its size on its own instead of blocking its writers upto this limit
+	// which is rounded as per Rounding, it cannot be combined with ExactCapacity
+	// It is 0 by default in which case the queue only grows via Resize()
+	GrowthLimit uint32
+}
+
+// apply turns the given size into the size of the ring along with the capacity of the queue as per the rounding
+func (self Rounding) apply(size uint32) (queueSize uint32, capacity uint32, err error) {
+	if size == 0 {
+		return 0, 0, errors.New("size must be positive")
+	} else if size > MaxQueueSize {
+		return 0, 0, fmt.Errorf("size %d exceeds MaxQueueSize %d", size, MaxQueueSize)
+	}
+	switch self {
+	case RoundUpToPowerOf2:
+		queueSize = nextGreaterPowerOf2(size)
+		capacity = queueSize
+	case RequirePowerOf2:
+		if size&(size-1) != 0 {
+			return 0, 0, fmt.Errorf("size %d is not a power of 2", size)
+		}
+		queueSize, capacity = size, size
+	case ExactCapacity:
+		queueSize, capacity = nextGreaterPowerOf2(size), size
+	default:
+		return 0, 0, fmt.Errorf("unknown rounding %d", self)
+	}
+	return
+}
+
+// validate checks the options and returns the size of the ring along with the capacity of the queue
+// and the size upto which it grows on its own
+func (self *Options) validate() (queueSize uint32, capacity uint32, growthLimit uint32, err error) {
+	if queueSize, capacity, err = self.Rounding.apply(self.Size); err != nil {
+		return 0, 0, 0, fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	switch self.FullPolicy {
+	case BlockWhenFull, DropNewest, DropOldest:
+	case Overwrite:
+		if self.Rounding == ExactCapacity {
+			return 0, 0, 0, fmt.Errorf("%w: a queue with an exact capacity cannot be overwritten in place", ErrInvalidOptions)
+		}
+	case OverflowWhenFull:
+		if self.Rounding == ExactCapacity {
+			return 0, 0, 0, fmt.Errorf("%w: a queue with an exact capacity cannot overflow", ErrInvalidOptions)
+		} else if self.GrowthLimit != 0 {
+			return 0, 0, 0, fmt.Errorf("%w: an unbounded queue has no growth limit", ErrInvalidOptions)
+		}
+		return queueSize, capacity, MaxQueueSize, nil
+	default:
+		return 0, 0, 0, fmt.Errorf("%w: unknown full policy %d", ErrInvalidOptions, self.FullPolicy)
+	}
+	if self.GrowthLimit == 0 {
+		return
+	} else if self.Rounding == ExactCapacity {
+		return 0, 0, 0, fmt.Errorf("%w: a queue with an exact capacity cannot grow", ErrInvalidOptions)
+	} else if self.GrowthLimit < self.Size {
+		return 0, 0, 0, fmt.Errorf("%w: growth limit %d is below size %d", ErrInvalidOptions, self.GrowthLimit, self.Size)
+	}
+	if growthLimit, _, err = self.Rounding.apply(self.GrowthLimit); err != nil {
+		return 0, 0, 0, fmt.Errorf("%w: growth limit: %v", ErrInvalidOptions, err)
+	}
+	return
+}
+
+// newContents allocates the slots of a queue and returns them along with the stride between adjacent ones
+// Padded slots are allocated as structs comprising of a slot and its padding
+// so that the garbage collector still keeps track of the payloads
+func newContents[T any](queueSize uint32, padded bool) (contents unsafe.Pointer, strideLength uintptr) {
+	strideLength = unsafe.Sizeof(slot[T]{})
+	if padding := (constants.CacheLinePadSize - strideLength%constants.CacheLinePadSize) % constants.CacheLinePadSize; padded && padding > 0 {
+		paddedSlot := reflect.StructOf([]reflect.StructField{
+			{Name: "Slot", Type: reflect.TypeOf(slot[T]{})},
+			{Name: "Pad", Type: reflect.ArrayOf(int(padding), reflect.TypeOf(byte(0)))},
+		})
+		return reflect.MakeSlice(reflect.SliceOf(paddedSlot), int(queueSize), int(queueSize)).UnsafePointer(), paddedSlot.Size()
+	}
+	return unsafe.Pointer(&make([]slot[T], queueSize, queueSize)[0]), strideLength
+}
@@ -0,0 +1,237 @@
+package zenq_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	for _, options := range []zenq.Options{
+		{},
+		{Size: zenq.MaxQueueSize + 1},
+		{Size: 10, Rounding: zenq.RequirePowerOf2},
+		{Size: 8, Rounding: 9},
+		{Size: 8, FullPolicy: 9},
+	} {
+		if zq, err := zenq.NewWithOptions[int](options); zq != nil || !errors.Is(err, zenq.ErrInvalidOptions) {
+			t.Fatalf("options %+v: %v", options, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		options  zenq.Options
+		capacity int
+	}{
+		{zenq.Options{Size: 10}, 16},
+		{zenq.Options{Size: 8, Rounding: zenq.RequirePowerOf2}, 8},
+		{zenq.Options{Size: 1}, 1},
+	} {
+		zq, err := zenq.NewWithOptions[int](tc.options)
+		if err != nil {
+			t.Fatalf("options %+v: %v", tc.options, err)
+		}
+		if c := zq.Cap(); c != tc.capacity {
+			t.Fatalf("capacity %d for options %+v, expected %d", c, tc.options, tc.capacity)
+		}
+	}
+	if c := zenq.New[int](0).Cap(); c != 1 {
+		t.Fatalf("capacity %d for size 0", c)
+	}
+}
+
+type paddedItem struct {
+	name  string
+	value *int
+	array [3]int
+}
+
+func TestPadSlots(t *testing.T) {
+	const numItems = 20000
+	zq, err := zenq.NewWithOptions[paddedItem](zenq.Options{Size: 64, PadSlots: true, WaitStrategy: zenq.Yielding{Spins: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < numItems; i++ {
+			value := i
+			zq.Write(paddedItem{name: string(rune('a' + i%26)), value: &value, array: [3]int{i, i, i}})
+			// the pointers held by padded slots must survive a GC cycle
+			if i%1000 == 0 {
+				runtime.GC()
+			}
+		}
+	}()
+	for i := 0; i < numItems; i++ {
+		item, queueOpen := zq.Read()
+		if !queueOpen || *item.value != i || item.array[2] != i || item.name != string(rune('a'+i%26)) {
+			t.Fatalf("item %d corrupted", i)
+		}
+	}
+	wg.Wait()
+}
+
+func TestNoSelectQueuesAreNeverSelected(t *testing.T) {
+	unselectable, _ := zenq.NewWithOptions[int](zenq.Options{Size: 4, NoSelect: true})
+	unselectable.Write(1)
+
+	// selections made up of such queues alone return right away instead of waiting for good
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if data := zenq.Select(unselectable); data != nil {
+			t.Errorf("Select() returned %v", data)
+		}
+		if index, _, _ := zenq.SelectIndex(unselectable, nil); index != -1 {
+			t.Errorf("SelectIndex() selected %d", index)
+		}
+		if index, _, _ := zenq.TrySelect(unselectable); index != -1 {
+			t.Errorf("TrySelect() selected %d", index)
+		}
+		if index, _, _ := zenq.SelectContext(context.Background(), unselectable); index != -1 {
+			t.Errorf("SelectContext() selected %d", index)
+		}
+		if selector := zenq.NewSelector(unselectable); selector.Len() != 0 {
+			t.Errorf("selector over %d streams", selector.Len())
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("selection over a NoSelect queue blocked")
+	}
+
+	// the other streams are selected from as usual
+	selectable := zenq.New[int](4)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		selectable.Write(2)
+	}()
+	if index, value, ok := zenq.SelectIndex(unselectable, selectable); index != 1 || value != 2 || !ok {
+		t.Fatalf("selected %v from %d, ok %t", value, index, ok)
+	}
+	selectable.Write(3)
+	if data := zenq.Select(unselectable, selectable); data != 3 {
+		t.Fatalf("selected %v", data)
+	}
+
+	// selecting from the queue by itself relies on no auxiliary goroutine
+	if value, index, ok := zenq.SelectOf(unselectable); index != 0 || value != 1 || !ok {
+		t.Fatalf("selected %d from %d, ok %t", value, index, ok)
+	}
+	if index, err := zenq.SelectWrite(4, unselectable); index != 0 || err != nil {
+		t.Fatalf("wrote to %d: %v", index, err)
+	}
+	if item, _ := unselectable.Read(); item != 4 {
+		t.Fatalf("read %d", item)
+	}
+}
+
+func TestExactCapacity(t *testing.T) {
+	zq, err := zenq.NewWithOptions[int](zenq.Options{Size: 5, Rounding: zenq.ExactCapacity})
+	if err != nil {
+		t.Fatal(err)
+	} else if c := zq.Cap(); c != 5 {
+		t.Fatalf("capacity %d, expected 5", c)
+	}
+
+	// the limit holds as the indices wrap around the underlying ring of 8 slots
+	for round := 0; round < 4; round++ {
+		for i := 0; i < 5; i++ {
+			if ok, err := zq.TryWrite(i); !ok || err != nil {
+				t.Fatalf("write %d in round %d, ok %t: %v", i, round, ok, err)
+			}
+		}
+		if ok, _ := zq.TryWrite(5); ok {
+			t.Fatalf("wrote beyond the capacity in round %d", round)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		if _, err := zq.WriteContext(ctx, 5); err == nil {
+			t.Fatalf("wrote beyond the capacity in round %d", round)
+		}
+		cancel()
+		for i := 0; i < 3; i++ {
+			if item, ok, _ := zq.TryRead(); !ok || item != i {
+				t.Fatalf("read %d in round %d, expected %d", item, round, i)
+			}
+		}
+		for i := 5; i < 8; i++ {
+			if ok, _ := zq.TryWrite(i); !ok {
+				t.Fatalf("write %d in round %d rejected", i, round)
+			}
+		}
+		if ok, _ := zq.TryWrite(8); ok {
+			t.Fatalf("wrote beyond the capacity in round %d", round)
+		}
+		for i := 3; i < 8; i++ {
+			if item, ok, _ := zq.TryRead(); !ok || item != i {
+				t.Fatalf("read %d in round %d, expected %d", item, round, i)
+			}
+		}
+	}
+
+	// a writer blocked at the limit gets through once a slot is read
+	for i := 0; i < 5; i++ {
+		zq.Write(i)
+	}
+	written := make(chan struct{})
+	go func() {
+		zq.Write(5)
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("wrote beyond the capacity")
+	case <-time.After(30 * time.Millisecond):
+	}
+	zq.Read()
+	<-written
+}
+
+func TestExactCapacityWhileContending(t *testing.T) {
+	for _, ws := range []zenq.WaitStrategy{nil, zenq.Yielding{}} {
+		const writers, perWriter = 4, 5000
+		zq, _ := zenq.NewWithOptions[int](zenq.Options{Size: 5, Rounding: zenq.ExactCapacity, WaitStrategy: ws})
+
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 1; i <= perWriter; i++ {
+					switch i % 3 {
+					case 0:
+						zq.Write(i)
+					case 1:
+						zq.WriteContext(context.Background(), i)
+					case 2:
+						for ok, _ := zq.TryWrite(i); !ok; ok, _ = zq.TryWrite(i) {
+							time.Sleep(time.Microsecond)
+						}
+					}
+				}
+			}()
+		}
+		sum := 0
+		for i := 0; i < writers*perWriter; i++ {
+			if size := zq.Len(); size > zq.Cap() {
+				t.Fatalf("%d items pending in a queue of capacity %d", size, zq.Cap())
+			}
+			item, _ := zq.Read()
+			sum += item
+		}
+		wg.Wait()
+		if expected := writers * perWriter * (perWriter + 1) / 2; sum != expected {
+			t.Fatalf("read values summing upto %d, expected %d", sum, expected)
+		}
+	}
+}
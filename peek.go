@@ -0,0 +1,38 @@
+package zenq
+
+// Peek returns the item at the head of the queue without reading it, neither the reader index nor the state of its slot
+// is changed hence the item is still returned by the next Read()
+// It returns ok = false if the queue is empty at the moment, it never waits for an item to be committed
+// With concurrent readers the item might be read by one of them as soon as Peek() returns
+func (self *ZenQ[T]) Peek() (data T, ok bool) {
+	var dst [1]T
+	if self.PeekN(dst[:]) == 1 {
+		return dst[0], true
+	}
+	return
+}
+
+// PeekN copies upto len(dst) items from the head of the queue into dst without reading them just like Peek()
+// It returns the number of items copied which falls short of len(dst) in case fewer items are committed at the moment
+// The items copied are the ones at the head of the queue as of the same moment, in FIFO order
+func (self *ZenQ[T]) PeekN(dst []T) (n int) {
+	for {
+		r := self.readRing.Load()
+		readerIndex := self.readerIndex.Load()
+		for n = 0; n < len(dst); n++ {
+			idx := readerIndex + uint32(n) + 1
+			// a committed value is only ever found in the ring serving its index just like in tryRead()
+			r = r.resolve(idx)
+			slot := r.slotAt(idx)
+			if slot.load() != newSlotState(idx, SlotCommitted) {
+				break
+			}
+			dst[n] = slot.item
+		}
+		// an item is only ever overwritten once its reader index is claimed by a reader or an overwriting writer,
+		// hence the copies are consistent as long as the reader index did not move meanwhile
+		if self.readerIndex.Load() == readerIndex {
+			return
+		}
+	}
+}
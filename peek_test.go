@@ -0,0 +1,129 @@
+package zenq_test
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+func TestPeek(t *testing.T) {
+	zq := zenq.New[int](8)
+	if _, ok := zq.Peek(); ok {
+		t.Fatal("peeked into an empty queue")
+	}
+	for i := 1; i <= 5; i++ {
+		zq.Write(i)
+	}
+	// peeking consumes nothing
+	for k := 0; k < 3; k++ {
+		if item, ok := zq.Peek(); !ok || item != 1 {
+			t.Fatalf("peeked %d, ok %t", item, ok)
+		}
+	}
+	dst := make([]int, 8)
+	if n := zq.PeekN(dst); n != 5 || dst[0] != 1 || dst[4] != 5 {
+		t.Fatalf("peeked %v", dst[:n])
+	}
+	for i := 1; i <= 5; i++ {
+		if item, _ := zq.Read(); item != i {
+			t.Fatalf("read %d, expected %d", item, i)
+		}
+		if item, ok := zq.Peek(); i < 5 && (!ok || item != i+1) {
+			t.Fatalf("peeked %d after reading %d, ok %t", item, i, ok)
+		} else if i == 5 && ok {
+			t.Fatal("peeked into a drained queue")
+		}
+	}
+	zq.Close()
+	if _, ok := zq.Peek(); ok {
+		t.Fatal("peeked into a closed queue")
+	}
+}
+
+func TestPeekResized(t *testing.T) {
+	zq := zenq.New[int](4)
+	for i := 1; i <= 4; i++ {
+		zq.Write(i)
+	}
+	if err := zq.Resize(16); err != nil {
+		t.Fatal(err)
+	}
+	for i := 5; i <= 10; i++ {
+		zq.Write(i)
+	}
+	// the items of the retired ring are peeked first
+	dst := make([]int, 16)
+	if n := zq.PeekN(dst); n != 10 {
+		t.Fatalf("peeked %v", dst[:n])
+	}
+	for i := 0; i < 10; i++ {
+		if dst[i] != i+1 {
+			t.Fatalf("peeked %v", dst[:10])
+		}
+	}
+}
+
+func TestPeekOverwrite(t *testing.T) {
+	zq, _ := zenq.NewWithOptions[int](zenq.Options{Size: 4, FullPolicy: zenq.Overwrite})
+	for i := 1; i <= 10; i++ {
+		zq.Write(i)
+	}
+	if item, ok := zq.Peek(); !ok || item != 7 {
+		t.Fatalf("peeked %d, ok %t", item, ok)
+	}
+}
+
+func TestPeekWhileStreaming(t *testing.T) {
+	type pair struct{ a, b int }
+	const numItems = 200000
+	zq := zenq.New[pair](16)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= numItems; i++ {
+			zq.Write(pair{i, -i})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= numItems; i++ {
+			if item, _ := zq.Read(); item.a != i {
+				t.Errorf("read %d, expected %d", item.a, i)
+				return
+			}
+		}
+	}()
+
+	// peekers never see torn items nor gaps among the items peeked at once
+	done := make(chan struct{})
+	var pg sync.WaitGroup
+	for p := 0; p < 2; p++ {
+		pg.Add(1)
+		go func() {
+			defer pg.Done()
+			dst := make([]pair, 4)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				runtime.Gosched()
+				n := zq.PeekN(dst)
+				for k := 0; k < n; k++ {
+					if dst[k].a != -dst[k].b || (k > 0 && dst[k].a != dst[k-1].a+1) {
+						t.Errorf("peeked %v", dst[:n])
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	pg.Wait()
+}
@@ -0,0 +1,67 @@
+package zenq
+
+// Reopen discards all the items of the queue and opens it afresh for both reads and writes, whether it was closed or not
+// so that the queue can be reused, for instance by a pool of queues
+// Every reader and writer operating on the queue meanwhile belongs to the older generation and finds the queue closed,
+// including the ones parked on it which are called right away, unless it claims its index only after the queue
+// is reopened in which case it operates on the fresh ring of the newer generation instead
+// It is safe to call Reopen() concurrently with any other method, the generation it opened is returned
+// A queue freed via Free() is never reopened, in which case the current generation is returned
+func (self *ZenQ[T]) Reopen() (generation uint32) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.selectionState.Load() == SelectionFreed {
+		return self.generation.Load()
+	}
+	from, r := self.readRing.Load(), self.writeRing.Load()
+	// the fresh ring is allocated beforehand just like in resize() so that indices claimed meanwhile wait only briefly
+	fresh := newRing[T](r.indexMask+1, r.capacity, self.padSlots)
+	r.sealed.Store(true)
+	// every index claimed from now on finds the ring sealed and waits for the fresh one, hence it belongs to the newer
+	// generation if it lies beyond the fence whereas every index before the fence belongs to the older generation
+	start := self.fence() + 1
+	// the rings are retired before the queue is open once again so that the readers and writers of the older
+	// generation find them retired by then
+	for ring := from; ring != nil; ring = ring.next.Load() {
+		ring.retired.Store(true)
+	}
+	fresh.startAt(start)
+	self.writeRing.Store(fresh)
+	self.readRing.Store(fresh)
+	self.closeErr.Store(nil)
+	Store8(&self.globalState, StateOpen)
+	generation = self.generation.Add(1)
+	r.end = start
+	r.next.Store(fresh)
+	// the goroutines parked on the retired rings give up once called
+	for ; from != fresh; from = from.next.Load() {
+		from.each(func(slot *slot[T]) {
+			slot.writeParker.readyAll()
+			self.readyAllReaders(slot)
+		})
+	}
+	return
+}
+
+// Generation returns the number of times the queue was reopened via Reopen() or Reset()
+func (self *ZenQ[T]) Generation() uint32 {
+	return self.generation.Load()
+}
+
+// fence moves both the writer and the reader index forward to the same index beyond every index claimed so far
+// and returns it
+// Both indices only ever move forward, hence every range of indices claimed meanwhile lies entirely either before
+// or beyond the fence as it is claimed via a single increment or CAS
+func (self *ZenQ[T]) fence() uint32 {
+	fence := self.writerIndex.Load() + 1
+	for {
+		writerIndex, readerIndex := self.writerIndex.Load(), self.readerIndex.Load()
+		if int32(writerIndex-fence) >= 0 {
+			fence = writerIndex + 1
+		} else if int32(readerIndex-fence) >= 0 {
+			fence = readerIndex + 1
+		} else if self.writerIndex.CompareAndSwap(writerIndex, fence) && self.readerIndex.CompareAndSwap(readerIndex, fence) {
+			return fence
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package zenq_test
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+func TestReopen(t *testing.T) {
+	zq := zenq.New[int](4)
+	for i := 0; i < 3; i++ {
+		zq.Write(i)
+	}
+	// the items of the previous generation are discarded
+	if generation := zq.Reopen(); generation != 1 || zq.Generation() != 1 {
+		t.Fatalf("generation %d once reopened", generation)
+	}
+	if zq.Len() != 0 || !zq.IsEmpty() || zq.State() != zenq.StateOpen {
+		t.Fatalf("length %d, state %d once reopened", zq.Len(), zq.State())
+	}
+	if item, ok, _ := zq.TryRead(); ok {
+		t.Fatalf("read %d of the previous generation", item)
+	}
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 4; i++ {
+			if zq.Write(i) {
+				t.Fatal("queue closed")
+			}
+		}
+		for i := 0; i < 4; i++ {
+			if item, queueOpen := zq.Read(); !queueOpen || item != i {
+				t.Fatalf("read %d, queue open %t", item, queueOpen)
+			}
+		}
+	}
+
+	// queues closed with a cause are reopened without it
+	zq.Write(7)
+	zq.CloseWithError(errors.New("closed"))
+	zq.Reopen()
+	if err := zq.Err(); err != nil || zq.IsClosed() {
+		t.Fatalf("reopened queue failed: %v", err)
+	}
+	zq.Write(8)
+	if item, queueOpen := zq.Read(); !queueOpen || item != 8 {
+		t.Fatalf("read %d, queue open %t", item, queueOpen)
+	}
+
+	// and so are fully closed ones, Reset() being the same as Reopen()
+	zq.Close()
+	if _, queueOpen := zq.Read(); queueOpen {
+		t.Fatal("read from a closed queue")
+	}
+	zq.Reset()
+	if generation := zq.Generation(); generation != 3 {
+		t.Fatalf("generation %d", generation)
+	}
+	zq.Write(9)
+	if item, queueOpen := zq.Read(); !queueOpen || item != 9 {
+		t.Fatalf("read %d, queue open %t", item, queueOpen)
+	}
+
+	// selections only ever see the current generation
+	zq.Write(1)
+	zq.Reopen()
+	zq.Write(2)
+	if data := zenq.Select(zq); data != 2 {
+		t.Fatalf("selected %v", data)
+	}
+}
+
+func TestReopenFailsParkedGoroutines(t *testing.T) {
+	zq, _ := zenq.NewWithOptions[int](zenq.Options{Size: 2, WaitStrategy: zenq.Blocking{}})
+	zq.Write(1)
+	zq.Write(2)
+
+	failedWrites := make(chan bool, 2)
+	for i := 0; i < 2; i++ {
+		go func() { failedWrites <- zq.Write(3) }()
+	}
+	time.Sleep(20 * time.Millisecond)
+	zq.Reopen()
+	for i := 0; i < 2; i++ {
+		select {
+		case failed := <-failedWrites:
+			if !failed {
+				t.Fatal("write of the previous generation succeeded")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("writer still waiting")
+		}
+	}
+	if n := zq.Len(); n != 0 {
+		t.Fatalf("length %d once reopened", n)
+	}
+
+	reads := make(chan bool, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, queueOpen := zq.Read()
+			reads <- queueOpen
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	zq.Reopen()
+	for i := 0; i < 2; i++ {
+		select {
+		case queueOpen := <-reads:
+			if queueOpen {
+				t.Fatal("read of the previous generation succeeded")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("reader still waiting")
+		}
+	}
+	zq.Write(5)
+	if item, queueOpen := zq.Read(); !queueOpen || item != 5 {
+		t.Fatalf("read %d, queue open %t", item, queueOpen)
+	}
+}
+
+func TestReopenWhileStreaming(t *testing.T) {
+	for _, policy := range []zenq.FullPolicy{zenq.BlockWhenFull, zenq.OverflowWhenFull} {
+		const writers, readers, reopens = 3, 3, 200
+		zq, _ := zenq.NewWithOptions[int](zenq.Options{Size: 4, FullPolicy: policy})
+
+		var stop atomic.Bool
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; !stop.Load(); i++ {
+					zq.Write(w<<24 | i)
+					if i%64 == 0 {
+						runtime.Gosched()
+					}
+				}
+			}(w)
+		}
+		seen := make([]map[int]bool, readers)
+		for r := 0; r < readers; r++ {
+			seen[r] = make(map[int]bool)
+			wg.Add(1)
+			go func(r int) {
+				defer wg.Done()
+				for !stop.Load() {
+					if item, queueOpen := zq.Read(); queueOpen {
+						if seen[r][item] {
+							t.Errorf("policy %d: read %d twice", policy, item)
+						}
+						seen[r][item] = true
+					}
+				}
+			}(r)
+		}
+		for i := 0; i < reopens; i++ {
+			time.Sleep(200 * time.Microsecond)
+			switch i % 3 {
+			case 0:
+				zq.Reopen()
+			case 1:
+				zq.Close()
+				zq.Reopen()
+			case 2:
+				zq.Resize(8)
+			}
+		}
+		stop.Store(true)
+		// the goroutines left waiting are failed by further generations
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		for waiting := true; waiting; {
+			select {
+			case <-done:
+				waiting = false
+			case <-time.After(time.Millisecond):
+				zq.Reopen()
+			}
+		}
+
+		// no item is read twice across generations
+		all := make(map[int]bool)
+		for r := range seen {
+			for item := range seen[r] {
+				if all[item] {
+					t.Fatalf("policy %d: read %d twice", policy, item)
+				}
+				all[item] = true
+			}
+		}
+		if generation := zq.Generation(); generation < reopens*2/3 {
+			t.Fatalf("policy %d: generation %d after %d reopens", policy, generation, reopens*2/3)
+		}
+
+		// and the queue is consistent afterwards
+		zq.Reopen()
+		for i := 0; i < 100; i++ {
+			if zq.Write(i) {
+				t.Fatalf("policy %d: queue closed", policy)
+			}
+			if item, queueOpen := zq.Read(); !queueOpen || item != i {
+				t.Fatalf("policy %d: read %d, queue open %t", policy, item, queueOpen)
+			}
+		}
+		if n := zq.Len(); n != 0 {
+			t.Fatalf("policy %d: length %d once drained", policy, n)
+		}
+	}
+}
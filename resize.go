@@ -0,0 +1,94 @@
+package zenq
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidSize is returned by Resize() wrapped along with the reason the size was rejected
+var ErrInvalidSize = errors.New("zenq: invalid size")
+
+// Resize grows the queue to the given size which is rounded as per the options the queue was created with
+// Neither the items in the queue nor its parked goroutines are affected, the indices claimed so far are still served
+// by the current ring whereas all later ones are served by a new ring of the given size
+// The current ring is garbage collected once drained, until then its items do not count against the new size
+// Queues never shrink, hence a size below the current one is rejected and queues with an exact capacity cannot be
+// resized at all, in either case an error wrapping ErrInvalidSize is returned whereas ErrClosed is returned
+// once the queue is closed
+func (self *ZenQ[T]) Resize(size uint32) error {
+	if self.rounding == ExactCapacity {
+		return fmt.Errorf("%w: queues with an exact capacity cannot be resized", ErrInvalidSize)
+	}
+	queueSize, _, err := self.rounding.apply(size)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSize, err)
+	}
+	for {
+		if Load8(&self.globalState) != StateOpen {
+			return ErrClosed
+		}
+		r := self.writeRing.Load()
+		if current := r.indexMask + 1; queueSize < current {
+			return fmt.Errorf("%w: size %d is below the current size %d", ErrInvalidSize, queueSize, current)
+		} else if queueSize == current || self.resize(r, queueSize) {
+			return nil
+		}
+	}
+}
+
+// grow makes room for n more writer indices beyond the given full ring in case the queue is allowed to grow any further
+// It returns whether the writers should retry as the queue was resized meanwhile
+func (self *ZenQ[T]) grow(r *ring[T], n uint32) (resized bool) {
+	if size := self.growth(r, n); size != 0 {
+		self.resize(r, size)
+	}
+	return self.writeRing.Load() != r
+}
+
+// growth returns the size of the ring replacing the given full ring for n more writer indices or 0 in case the queue
+// cannot grow any further
+// Unbounded queues append an overflow segment of the same size as the ring unless n is larger, so that the memory
+// held drops back once the backlog is drained, whereas other queues double the size of the ring upto their growth limit
+func (self *ZenQ[T]) growth(r *ring[T], n uint32) uint32 {
+	switch size := r.indexMask + 1; {
+	case self.fullPolicy == OverflowWhenFull:
+		if n > size {
+			size = nextGreaterPowerOf2(n)
+		}
+		// the difference between writer and reader indices must stay within MaxQueueSize
+		if backlog := int32(self.writerIndex.Load() - self.readerIndex.Load()); int64(backlog)+int64(size) <= MaxQueueSize {
+			return size
+		}
+	case size < self.growthLimit:
+		// both the size and the growth limit are powers of 2
+		return size << 1
+	}
+	return 0
+}
+
+// resize replaces the given ring with a new one of the given size for all indices beyond the ones claimed so far
+// It returns false without doing anything in case the ring was already replaced by a concurrent resize
+func (self *ZenQ[T]) resize(from *ring[T], queueSize uint32) bool {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.writeRing.Load() != from {
+		return false
+	}
+	// the new ring is allocated beforehand so that writers and readers are only held up while its turns are assigned
+	r := newRing[T](queueSize, queueSize, self.padSlots)
+	from.sealed.Store(true)
+	// writer indices are claimed in a total order, hence every index claimed after this load finds the old ring sealed
+	// and waits for the new ring whereas every index claimed before belongs to the old ring
+	end := self.writerIndex.Load() + 1
+	r.startAt(end)
+	from.end = end
+	from.next.Store(r)
+	self.writeRing.Store(r)
+	// readers ahead of the writers might be parked on the old ring awaiting indices which are served by the new ring
+	// from now on, so are the writers waiting for a slot without having claimed an index yet
+	from.each(func(slot *slot[T]) {
+		self.readyAllReaders(slot)
+		slot.writeParker.readyAll()
+	})
+	return true
+}
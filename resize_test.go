@@ -0,0 +1,225 @@
+package zenq_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+// streamWhileResizing checks that every value written via every kind of write is read exactly once and in the order
+// of its writer via every kind of read while resize is called over and over again
+func streamWhileResizing(t *testing.T, zq *zenq.ZenQ[int], writers, perWriter int, resize func(i int)) {
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				value := w*perWriter + i
+				switch i % 4 {
+				case 0:
+					zq.Write(value)
+				case 1:
+					for ok, _ := zq.TryWrite(value); !ok; ok, _ = zq.TryWrite(value) {
+					}
+				case 2:
+					if _, err := zq.WriteContext(context.Background(), value); err != nil {
+						t.Error(err)
+					}
+				case 3:
+					item, seq, _ := zq.Claim()
+					*item = value
+					zq.Publish(seq)
+				}
+			}
+		}(w)
+	}
+	done := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			resize(i)
+			time.Sleep(50 * time.Microsecond)
+		}
+	}()
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	last := make([]int, writers)
+	for i := range last {
+		last[i] = -1
+	}
+	seen := make([]bool, writers*perWriter)
+	batch := make([]int, 7)
+	for n := 0; n < writers*perWriter; {
+		var values []int
+		switch n % 3 {
+		case 0:
+			item, queueOpen := zq.Read()
+			if !queueOpen {
+				t.Fatal("queue closed")
+			}
+			values = []int{item}
+		case 1:
+			item, queueOpen, err := zq.ReadContext(context.Background())
+			if !queueOpen || err != nil {
+				t.Fatalf("queue closed: %v", err)
+			}
+			values = []int{item}
+		case 2:
+			k := writers*perWriter - n
+			if k > len(batch) {
+				k = len(batch)
+			}
+			read, _ := zq.ReadBatch(batch[:k])
+			values = batch[:read]
+		}
+		for _, value := range values {
+			if seen[value] {
+				t.Fatalf("read %d twice", value)
+			}
+			seen[value] = true
+			if w := value / perWriter; value <= last[w] {
+				t.Fatalf("read %d after %d", value, last[w])
+			} else {
+				last[w] = value
+			}
+			n++
+		}
+	}
+}
+
+func TestResizeWhileStreaming(t *testing.T) {
+	zq := zenq.New[int](2)
+	streamWhileResizing(t, zq, 4, 20000, func(i int) {
+		if i < 14 {
+			if err := zq.Resize(uint32(2) << i); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	if c := zq.Cap(); c < 1<<10 {
+		t.Fatalf("capacity %d after resizing", c)
+	}
+}
+
+func TestGrowthLimit(t *testing.T) {
+	zq, err := zenq.NewWithOptions[int](zenq.Options{Size: 2, GrowthLimit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the limit is rounded up just like the size
+	for i := 0; i < 128; i++ {
+		zq.Write(i)
+	}
+	if c := zq.Cap(); c != 128 {
+		t.Fatalf("capacity %d after growing", c)
+	}
+	n := 128
+	for ; ; n++ {
+		if ok, _ := zq.TryWrite(n); !ok {
+			break
+		}
+	}
+	if c := zq.Cap(); n >= 256 || c != 128 {
+		t.Fatalf("grew beyond the limit to a capacity of %d holding %d items", c, n)
+	}
+	for i := 0; i < n; i++ {
+		if item, _ := zq.Read(); item != i {
+			t.Fatalf("read %d, expected %d", item, i)
+		}
+	}
+
+	growing, _ := zenq.NewWithOptions[int](zenq.Options{Size: 1, GrowthLimit: 1 << 12})
+	streamWhileResizing(t, growing, 4, 20000, func(int) {})
+}
+
+func TestResizeWithParkedReadersThenClose(t *testing.T) {
+	const readers, written = 8, 6
+	zq := zenq.New[int](4)
+
+	var wg sync.WaitGroup
+	read := make(chan int, readers)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if item, queueOpen := zq.Read(); queueOpen {
+				read <- item
+			} else {
+				read <- -1
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := zq.Resize(64); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < written; i++ {
+		zq.Write(i)
+	}
+	zq.Close()
+	wg.Wait()
+	close(read)
+
+	// the readers parked on the retired ring are served by the new one or else by the closing
+	items, closed := 0, 0
+	for item := range read {
+		if item < 0 {
+			closed++
+		} else {
+			items++
+		}
+	}
+	if items != written || closed != readers-written {
+		t.Fatalf("%d readers got items and %d found the queue closed", items, closed)
+	}
+	if err := zq.Resize(128); !errors.Is(err, zenq.ErrClosed) {
+		t.Fatalf("resized a closed queue: %v", err)
+	}
+}
+
+func TestResizeInvalidSize(t *testing.T) {
+	zq := zenq.New[int](16)
+	for _, size := range []uint32{0, 8} {
+		if err := zq.Resize(size); !errors.Is(err, zenq.ErrInvalidSize) {
+			t.Fatalf("resized to %d: %v", size, err)
+		}
+	}
+	if err := zq.Resize(16); err != nil {
+		t.Fatal(err)
+	}
+	if err := zq.Resize(17); err != nil || zq.Cap() != 32 {
+		t.Fatalf("capacity %d after resizing to 17: %v", zq.Cap(), err)
+	}
+
+	exact, _ := zenq.NewWithOptions[int](zenq.Options{Size: 5, Rounding: zenq.ExactCapacity})
+	if err := exact.Resize(64); !errors.Is(err, zenq.ErrInvalidSize) {
+		t.Fatalf("resized a queue of exact capacity: %v", err)
+	}
+	powerOf2, _ := zenq.NewWithOptions[int](zenq.Options{Size: 8, Rounding: zenq.RequirePowerOf2})
+	if err := powerOf2.Resize(24); !errors.Is(err, zenq.ErrInvalidSize) {
+		t.Fatalf("resized to 24: %v", err)
+	}
+
+	for _, options := range []zenq.Options{
+		{Size: 8, GrowthLimit: 4},
+		{Size: 8, Rounding: zenq.ExactCapacity, GrowthLimit: 16},
+		{Size: 8, Rounding: zenq.RequirePowerOf2, GrowthLimit: 24},
+		{Size: 8, GrowthLimit: zenq.MaxQueueSize + 1},
+	} {
+		if _, err := zenq.NewWithOptions[int](options); !errors.Is(err, zenq.ErrInvalidOptions) {
+			t.Fatalf("options %+v: %v", options, err)
+		}
+	}
+}
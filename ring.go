@@ -0,0 +1,121 @@
+package zenq
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// ring is the ringbuffer of slots serving every index from its start onwards
+// Once the queue is resized, the indices from its end onwards are served by the next ring instead
+// and the ring is garbage collected as soon as the goroutines still operating on its indices are done
+type ring[T any] struct {
+	// strideLength is the size of a slot including its payload, hence it must be a full word
+	// as payloads can be arbitrarily large
+	strideLength uintptr
+	contents     unsafe.Pointer
+	indexMask    uint32
+	// the maximum number of items in the queue which is less than the size of the ring only for exact capacities
+	capacity uint32
+	// the first index served by the ring
+	start uint32
+	// sealed is set once a resize begins, end and next are valid only once next is non-nil
+	sealed atomic.Bool
+	end    uint32
+	next   atomic.Pointer[ring[T]]
+	// retired is set once the queue is reopened, the indices still served by the ring belong to an older generation
+	// hence every operation on them fails
+	retired atomic.Bool
+}
+
+// newRing allocates a ring of the given size whose slots are yet to be assigned their first turns via startAt()
+func newRing[T any](queueSize, capacity uint32, padded bool) *ring[T] {
+	r := &ring[T]{indexMask: queueSize - 1, capacity: capacity}
+	r.contents, r.strideLength = newContents[T](queueSize, padded)
+	return r
+}
+
+// startAt assigns the slots their turns for the first lap of the ring which starts at the given index
+func (self *ring[T]) startAt(start uint32) {
+	self.start = start
+	for idx := start; idx != start+self.indexMask+1; idx++ {
+		self.slotAt(idx).store(newSlotState(idx, SlotEmpty))
+	}
+}
+
+// returns the slot mapped to the given reader/writer index
+func (self *ring[T]) slotAt(idx uint32) *slot[T] {
+	return (*slot[T])(unsafe.Pointer(self.strideLength*(uintptr(self.indexMask)&uintptr(idx)) + uintptr(self.contents)))
+}
+
+// resolve returns the ring serving the given index starting from this one
+// In case a resize is underway, it waits for the next ring to be published
+func (self *ring[T]) resolve(idx uint32) *ring[T] {
+	r := self
+	for r.sealed.Load() {
+		next := r.next.Load()
+		if next == nil {
+			mcall(gosched_m)
+			continue
+		} else if int32(idx-r.end) < 0 {
+			break
+		}
+		r = next
+	}
+	return r
+}
+
+// roomFor returns whether the given writer index lies within the capacity of the queue as per the reads so far
+// which is implied by the turn of its slot unless the capacity is exact
+func (self *ring[T]) roomFor(idx uint32) bool {
+	if self.capacity > self.indexMask {
+		return true
+	}
+	// the reader index which makes room for the writer index, its slot moves on to a later turn once read
+	limiter := idx - self.capacity
+	return int32(self.slotAt(limiter).load().turn()-limiter) > 0
+}
+
+// vacant returns whether the writers of the n indices following the given writer index can proceed right away
+// or else whether the first of the indices not vacant was claimed already
+func (self *ring[T]) vacant(writerIndex, n uint32) (vacant bool, stale bool) {
+	if n > self.capacity {
+		return false, false
+	}
+	for idx := writerIndex + 1; idx != writerIndex+n+1; idx++ {
+		if turn := self.slotAt(idx).load().turn(); turn != idx {
+			return false, int32(turn-idx) > 0
+		} else if !self.roomFor(idx) {
+			return false, false
+		}
+	}
+	return true, false
+}
+
+// writable returns whether the writer of the given index can proceed without waiting any further
+func (self *ring[T]) writable(slot *slot[T], idx uint32) bool {
+	return slot.load().turn() == idx && self.roomFor(idx)
+}
+
+// writeParkerFor returns the parker for a writer of the given index which is the one of its own slot until its turn
+// comes and thereafter the one of the slot whose read makes room for it in case of an exact capacity, along with
+// the turn of that slot the writer waits for
+func (self *ring[T]) writeParkerFor(slot *slot[T], idx uint32) (*ThreadParker[T], uint32) {
+	if slot.load().turn() != idx {
+		return &slot.writeParker, idx
+	}
+	// the read of the limiter releases its slot to the lap following it
+	limiter := idx - self.capacity
+	return &self.slotAt(limiter).writeParker, limiter + self.indexMask + 1
+}
+
+// owns returns whether the given index is still served by this ring as far as is known at the moment
+func (self *ring[T]) owns(idx uint32) bool {
+	return !self.sealed.Load() || self.next.Load() == nil || int32(idx-self.end) < 0
+}
+
+// each calls fn for every slot of the ring
+func (self *ring[T]) each(fn func(*slot[T])) {
+	for pos := uint32(0); pos <= self.indexMask; pos++ {
+		fn(self.slotAt(pos))
+	}
+}
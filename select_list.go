@@ -1,19 +1,13 @@
 package zenq
 
 import (
-	"sync"
 	"sync/atomic"
 	"unsafe"
 )
 
-// global memory pool for storing and leasing node objects
-var (
-	nodePool = sync.Pool{New: func() any { return new(node) }}
-	nodeGet  = nodePool.Get
-	nodePut  = nodePool.Put
-)
-
 // List is a lock-free linked list
+// Nodes are never recycled as a concurrent Dequeue() or Prune() might still hold a dequeued one, a recycled node
+// becoming the head of the same list once again would let the CAS of that call succeed on a stale head
 // theory -> https://www.cs.rochester.edu/u/scott/papers/1996_PODC_queues.pdf
 // pseudocode -> https://www.cs.rochester.edu/research/synchronization/pseudocode/queues.html
 type List struct {
@@ -23,9 +17,7 @@ type List struct {
 
 // NewList returns a new list
 func NewList() List {
-	n := nodeGet().(*node)
-	n.threadPtr, n.dataOut = nil, nil
-	n.next.Store(nil)
+	n := new(node)
 	var ptr atomic.Pointer[node]
 	ptr.Store(n)
 	return List{head: ptr, tail: ptr}
@@ -36,15 +28,23 @@ type node struct {
 	next      atomic.Pointer[node]
 	threadPtr *unsafe.Pointer
 	dataOut   *any
+	// whether the thread pointer is the one of a selectorSpot
+	spot bool
 }
 
 // Enqueue inserts a value into the list
 func (l *List) Enqueue(threadPtr *unsafe.Pointer, dataOut *any) {
-	var (
-		n          = nodeGet().(*node)
-		tail, next *node
-	)
-	n.threadPtr, n.dataOut = threadPtr, dataOut
+	l.enqueue(&node{threadPtr: threadPtr, dataOut: dataOut})
+}
+
+// enqueueSpot inserts the thread pointer of a selectorSpot into the list
+func (l *List) enqueueSpot(threadPtr *unsafe.Pointer, dataOut *any) {
+	l.enqueue(&node{threadPtr: threadPtr, dataOut: dataOut, spot: true})
+}
+
+// enqueue inserts a node into the list
+func (l *List) enqueue(n *node) {
+	var tail, next *node
 	for {
 		tail = l.tail.Load()
 		next = tail.next.Load()
@@ -62,9 +62,21 @@ func (l *List) Enqueue(threadPtr *unsafe.Pointer, dataOut *any) {
 	}
 }
 
-// Dequeue removes and returns the value at the head of the queue to the memory pool
+// Empty returns whether the list has no nodes at the moment
+func (l *List) Empty() bool {
+	return l.head.Load().next.Load() == nil
+}
+
+// Dequeue removes and returns the value at the head of the queue
 // It returns nil if the list is empty
 func (l *List) Dequeue() (threadPtr *unsafe.Pointer, dataOut *any) {
+	threadPtr, dataOut, _ = l.dequeue()
+	return
+}
+
+// dequeue removes and returns the value at the head of the queue along with whether its thread pointer is the one
+// of a selectorSpot
+func (l *List) dequeue() (threadPtr *unsafe.Pointer, dataOut *any, spot bool) {
 	var head, tail, next *node
 	for {
 		head = l.head.Load()
@@ -73,21 +85,50 @@ func (l *List) Dequeue() (threadPtr *unsafe.Pointer, dataOut *any) {
 		if head == l.head.Load() { // are head, tail, and next consistent?
 			if head == tail { // is list empty or tail falling behind?
 				if next == nil { // is list empty?
-					return nil, nil
+					return nil, nil, false
 				}
 				// tail is falling behind.  try to advance it
 				l.tail.CompareAndSwap(tail, next)
 			} else {
 				// read value before CAS_node otherwise another dequeue might free the next node
-				threadPtr, dataOut = next.threadPtr, next.dataOut
+				threadPtr, dataOut, spot = next.threadPtr, next.dataOut, next.spot
 				if l.head.CompareAndSwap(head, next) {
-					// sysFreeOS(unsafe.Pointer(head), nodeSize)
+					// the link of the dequeued node is left intact, in case it was cleared an Enqueue() still holding it
+					// as a stale tail could append its node to it which would never be dequeued
 					head.threadPtr, head.dataOut = nil, nil
-					head.next.Store(nil)
-					nodePut(head)
 					return // Dequeue is done.  return
 				}
 			}
 		}
 	}
 }
+
+// Prune removes the nodes at the head of the list whose selectors were acquired already
+// It stops at the first node whose selector is still waiting
+func (l *List) Prune() {
+	var head, tail, next *node
+	for {
+		head = l.head.Load()
+		tail = l.tail.Load()
+		next = head.next.Load()
+		if head == l.head.Load() {
+			if head == tail {
+				if next == nil {
+					return
+				}
+				l.tail.CompareAndSwap(tail, next)
+			} else {
+				// read value before CAS_node otherwise another dequeue might free the next node
+				threadPtr := next.threadPtr
+				if head != l.head.Load() || threadPtr == nil {
+					continue
+				} else if atomic.LoadPointer(threadPtr) != nil {
+					return
+				}
+				if l.head.CompareAndSwap(head, next) {
+					head.threadPtr, head.dataOut = nil, nil
+				}
+			}
+		}
+	}
+}
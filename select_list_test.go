@@ -0,0 +1,79 @@
+package zenq_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+func TestListConcurrentDequeueAndPrune(t *testing.T) {
+	const producers, perProducer = 4, 20000
+	var (
+		list     = zenq.NewList()
+		waiting  = unsafe.Pointer(new(int))
+		outs     = make([]any, producers*perProducer)
+		threads  = make([]unsafe.Pointer, producers*perProducer)
+		dequeued = make([]atomic.Int32, producers*perProducer)
+	)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := p * perProducer; i < (p+1)*perProducer; i++ {
+				// every third selector was acquired already so that Prune() removes it
+				if i%3 != 0 {
+					threads[i] = waiting
+				}
+				outs[i] = i
+				list.Enqueue(&threads[i], &outs[i])
+			}
+		}(p)
+	}
+	stop := make(chan struct{})
+	var consumers sync.WaitGroup
+	for c := 0; c < 3; c++ {
+		consumers.Add(1)
+		go func(c int) {
+			defer consumers.Done()
+			for {
+				if c == 0 {
+					list.Prune()
+				}
+				if _, dataOut := list.Dequeue(); dataOut != nil {
+					dequeued[(*dataOut).(int)].Add(1)
+					continue
+				}
+				select {
+				case <-stop:
+					return
+				default:
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+	close(stop)
+	consumers.Wait()
+	for {
+		_, dataOut := list.Dequeue()
+		if dataOut == nil {
+			break
+		}
+		dequeued[(*dataOut).(int)].Add(1)
+	}
+
+	if !list.Empty() {
+		t.Fatal("list not empty once drained")
+	}
+	// waiting selectors are never pruned and every node is dequeued at most once
+	for i := range dequeued {
+		if n := dequeued[i].Load(); n > 1 || (n == 0 && i%3 != 0) {
+			t.Fatalf("node %d dequeued %d times", i, n)
+		}
+	}
+}
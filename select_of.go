@@ -0,0 +1,83 @@
+package zenq
+
+// SelectOf selects a single element out of multiple ZenQs of the same type just like SelectIndex() but hands the
+// element over as is instead of as an interface, hence nothing is allocated unless the selector has to park
+// It returns the element along with the index of the ZenQ selected and ok as true, or else ok as false along with the
+// zero value in case the ZenQ selected is closed and drained, its cause is reported by Err() of the ZenQ
+// The ZenQs are tried starting from a random one, nil ZenQs are never selected and -1 is returned only in case
+// every ZenQ is nil
+// Unlike Select() the selector reads from the ZenQs by itself and waits on all of them at once just like Read() does
+// on a single ZenQ, hence it relies on no auxillary thread
+func SelectOf[T any](queues ...*ZenQ[T]) (value T, index int, ok bool) {
+	var ws WaitStrategy
+	for _, queue := range queues {
+		if queue != nil {
+			ws = queue.waitStrategy
+			break
+		}
+	}
+	if ws == nil {
+		return value, -1, false
+	}
+	for attempt := uint32(0); ; attempt++ {
+		if value, index, ok = trySelectOf(queues); index != -1 {
+			return
+		} else if !ws.Wait(attempt) {
+			continue
+		}
+		var (
+			parkers []*ThreadParker[T]
+			spots   []*waitSpot
+			ready   []func() bool
+		)
+		for _, queue := range queues {
+			if queue == nil {
+				continue
+			}
+			queue, r, readerIndex := queue, queue.readRing.Load(), queue.readerIndex.Load()
+			r = r.resolve(readerIndex + 1)
+			slot := r.slotAt(readerIndex + 1)
+			parkers = append(parkers, &slot.readParker)
+			spots = append(spots, queue.newSpot(readerIndex+1))
+			ready = append(ready, func() bool {
+				// the auxillary thread of the queue reading ahead for Select() moves its reader index as well and leaves
+				// the element in the backlog in case nobody selects it
+				return queue.backlog.Load() != nil || queue.readable(slot, readerIndex+1) ||
+					queue.readerIndex.Load() != readerIndex || !r.owns(readerIndex+1)
+			})
+		}
+		parkUntilAny(nil, parkers, spots, func() bool {
+			for _, readable := range ready {
+				if readable() {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// trySelectOf selects a single element or a closed and drained ZenQ out of the given ZenQs without waiting
+// starting from a random one, the elements read ahead by the auxillary thread of a ZenQ are selected first
+// It returns -1 in case there is nothing to select
+func trySelectOf[T any](queues []*ZenQ[T]) (value T, index int, ok bool) {
+	if len(queues) == 0 {
+		return value, -1, false
+	}
+	start := int(Fastrand() % uint32(len(queues)))
+	for n := range queues {
+		index = (start + n) % len(queues)
+		queue := queues[index]
+		if queue == nil {
+			continue
+		}
+		if value, ok = queue.takeBacklog(); ok {
+			return
+		}
+		var closed bool
+		if value, ok, closed = queue.tryRead(); ok || closed {
+			return
+		}
+	}
+	return value, -1, false
+}
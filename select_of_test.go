@@ -0,0 +1,169 @@
+package zenq_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+type typedItem struct {
+	a, b, c int
+	s       string
+}
+
+func TestSelectOf(t *testing.T) {
+	if _, index, ok := zenq.SelectOf[int](); index != -1 || ok {
+		t.Fatalf("selected from %d out of no queues", index)
+	}
+	if _, index, ok := zenq.SelectOf[int](nil, nil); index != -1 || ok {
+		t.Fatalf("selected from %d out of nil queues", index)
+	}
+
+	zqs := []*zenq.ZenQ[typedItem]{zenq.New[typedItem](4), nil, zenq.New[typedItem](4)}
+	zqs[2].Write(typedItem{a: 2})
+	if item, index, ok := zenq.SelectOf(zqs...); !ok || index != 2 || item.a != 2 {
+		t.Fatalf("selected %v from %d, ok %t", item, index, ok)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		zqs[0].Write(typedItem{a: 5})
+	}()
+	if item, index, ok := zenq.SelectOf(zqs...); !ok || index != 0 || item.a != 5 {
+		t.Fatalf("selected %v from %d, ok %t", item, index, ok)
+	}
+
+	// the element read ahead by the auxiliary goroutine is selected first
+	zqs[0].Write(typedItem{a: 7})
+	zqs[0].Write(typedItem{a: 8})
+	if index, _, _ := zenq.TrySelect(zqs[0]); index != -1 {
+		t.Fatalf("selected from %d right away", index)
+	}
+	time.Sleep(10 * time.Millisecond)
+	for _, expected := range []int{7, 8} {
+		if item, index, ok := zenq.SelectOf(zqs[0]); !ok || index != 0 || item.a != expected {
+			t.Fatalf("selected %v from %d, ok %t", item, index, ok)
+		}
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		zqs[2].Close()
+	}()
+	if item, index, ok := zenq.SelectOf(zqs...); ok || index != 2 || item.a != 0 {
+		t.Fatalf("selected %v from %d, ok %t", item, index, ok)
+	}
+	zqs[0].Free()
+	if _, index, ok := zenq.SelectOf(zqs[0]); ok || index != 0 {
+		t.Fatalf("selected from %d, ok %t", index, ok)
+	}
+}
+
+func TestSelectOfWhileContending(t *testing.T) {
+	for _, ws := range []zenq.WaitStrategy{zenq.Blocking{}, zenq.Yielding{Spins: 4}} {
+		const numQueues, perQueue, readers = 64, 2000, 4
+		zqs := make([]*zenq.ZenQ[int], numQueues)
+		for i := range zqs {
+			zqs[i], _ = zenq.NewWithOptions[int](zenq.Options{Size: 8, WaitStrategy: ws})
+		}
+		var wg sync.WaitGroup
+		for i := range zqs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < perQueue; j++ {
+					zqs[i].Write(i*perQueue + j)
+				}
+			}(i)
+		}
+
+		var mutex sync.Mutex
+		remaining := numQueues * perQueue
+		selected := make([][]int, readers)
+		var rg sync.WaitGroup
+		for r := 0; r < readers; r++ {
+			rg.Add(1)
+			go func(r int) {
+				defer rg.Done()
+				for {
+					mutex.Lock()
+					if remaining == 0 {
+						mutex.Unlock()
+						return
+					}
+					remaining--
+					mutex.Unlock()
+					item, index, ok := zenq.SelectOf(zqs...)
+					if !ok || item/perQueue != index {
+						t.Errorf("selected %d from %d, ok %t", item, index, ok)
+						return
+					}
+					selected[r] = append(selected[r], item)
+				}
+			}(r)
+		}
+		wg.Wait()
+		rg.Wait()
+
+		// every element is selected exactly once and in the order of its queue as seen by each reader
+		seen := make([]bool, numQueues*perQueue)
+		for r := range selected {
+			last := make([]int, numQueues)
+			for i := range last {
+				last[i] = -1
+			}
+			for _, item := range selected[r] {
+				if seen[item] {
+					t.Fatalf("selected %d twice", item)
+				}
+				seen[item] = true
+				if item <= last[item/perQueue] {
+					t.Fatalf("selected %d after %d", item, last[item/perQueue])
+				}
+				last[item/perQueue] = item
+			}
+		}
+		for item, s := range seen {
+			if !s {
+				t.Fatalf("%d never selected", item)
+			}
+		}
+	}
+}
+
+func TestSelectOfAllocatesNothing(t *testing.T) {
+	zq1, zq2 := zenq.New[typedItem](64), zenq.New[typedItem](64)
+	allocs := testing.AllocsPerRun(1000, func() {
+		zq2.Write(typedItem{a: 1, s: "x"})
+		if item, index, ok := zenq.SelectOf(zq1, zq2); !ok || index != 1 || item.a != 1 {
+			t.Fatalf("selected %v from %d, ok %t", item, index, ok)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("%.2f allocations per selection", allocs)
+	}
+}
+
+func TestSelectOfWhileReadingAhead(t *testing.T) {
+	zq := zenq.New[int](4)
+	defer zq.Free()
+	for i := 0; i < 2000; i++ {
+		selected := make(chan int)
+		go func() {
+			item, _, _ := zenq.SelectOf(zq)
+			selected <- item
+		}()
+		// the auxillary thread reads the element ahead while SelectOf() waits for it
+		zq.Signal()
+		zq.Write(i)
+		select {
+		case item := <-selected:
+			if item != i {
+				t.Fatalf("selected %d instead of %d", item, i)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("selection %d still waiting with %d read ahead", i, i)
+		}
+	}
+}
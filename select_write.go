@@ -0,0 +1,114 @@
+package zenq
+
+import "context"
+
+// SelectWrite writes a value to whichever of the given ZenQs has room for it first and returns the index of that ZenQ
+// The ZenQs are tried starting from a random one so that the values are spread across the ZenQs with room, in case
+// none has room the writer waits until one of them has as per the wait strategy of the first ZenQ
+// The value is written only once a ZenQ has room for it just like in SendContext(), hence full policies dropping or
+// overwriting values never apply
+// nil ZenQs and the ones closed for writes are never selected, once all of them are ErrClosed is returned along with -1
+func SelectWrite[T any](value T, queues ...*ZenQ[T]) (index int, err error) {
+	return selectWrite(nil, value, queues)
+}
+
+// TrySelectWrite writes a value to any of the given ZenQs just like SelectWrite() but only in case it can be done
+// without waiting, otherwise ErrFull is returned along with -1
+func TrySelectWrite[T any](value T, queues ...*ZenQ[T]) (index int, err error) {
+	if index, numOpen := trySelectWrite(value, queues); index != -1 {
+		return index, nil
+	} else if numOpen == 0 {
+		return -1, ErrClosed
+	}
+	return -1, ErrFull
+}
+
+// SelectWriteContext writes a value to any of the given ZenQs just like SelectWrite() but gives up waiting for room
+// once ctx is done, in which case the value is not written and the error matches ErrTimeout or ErrCancelled along
+// with ctx.Err()
+func SelectWriteContext[T any](ctx context.Context, value T, queues ...*ZenQ[T]) (index int, err error) {
+	if ctx.Err() != nil {
+		return -1, contextError(ctx)
+	}
+	if index, err = selectWrite(ctx, value, queues); err == ErrCancelled {
+		return -1, contextError(ctx)
+	}
+	return
+}
+
+// selectWrite implements the blocking selective writes, a nil ctx means waiting indefinitely
+// Just like the writes waiting for a free slot in write(), the writer claims an index only once it has room
+// and parks on the parkers of the next writer indices of all the ZenQs at once meanwhile
+func selectWrite[T any](ctx context.Context, value T, queues []*ZenQ[T]) (index int, err error) {
+	ctx = cancellable(ctx)
+	var ws WaitStrategy
+	for _, queue := range queues {
+		if queue != nil {
+			ws = queue.waitStrategy
+			break
+		}
+	}
+	for attempt := uint32(0); ; attempt++ {
+		if index, numOpen := trySelectWrite(value, queues); index != -1 {
+			return index, nil
+		} else if numOpen == 0 {
+			return -1, ErrClosed
+		} else if isDone(ctx) {
+			return -1, ErrCancelled
+		} else if !ws.Wait(attempt) {
+			continue
+		}
+		var (
+			parkers []*ThreadParker[T]
+			spots   []*waitSpot
+			rooms   []func() bool
+		)
+		for _, queue := range queues {
+			if queue == nil || Load8(&queue.globalState) != StateOpen {
+				continue
+			}
+			queue, r, writerIndex := queue, queue.writeRing.Load(), queue.writerIndex.Load()
+			r = r.resolve(writerIndex + 1)
+			slot := r.slotAt(writerIndex + 1)
+			parker, awaited := r.writeParkerFor(slot, writerIndex+1)
+			parkers = append(parkers, parker)
+			spots = append(spots, queue.newSpot(awaited))
+			rooms = append(rooms, func() bool {
+				// closing and reopening the queue move its writer index as well
+				return r.writable(slot, writerIndex+1) || queue.writerIndex.Load() != writerIndex || !r.owns(writerIndex+1)
+			})
+		}
+		if len(parkers) == 0 {
+			continue
+		}
+		parkUntilAny(ctx, parkers, spots, func() bool {
+			for _, room := range rooms {
+				if room() {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// trySelectWrite writes a value to the first of the given ZenQs with room for it starting from a random one
+// It returns the index of that ZenQ or -1 in case none has room along with the number of ZenQs open for writes
+func trySelectWrite[T any](value T, queues []*ZenQ[T]) (index int, numOpen int) {
+	if len(queues) == 0 {
+		return -1, 0
+	}
+	start := int(Fastrand() % uint32(len(queues)))
+	for n := range queues {
+		index = (start + n) % len(queues)
+		if queues[index] == nil {
+			continue
+		}
+		if ok, err := queues[index].TryWrite(value); ok {
+			return index, numOpen
+		} else if err == nil {
+			numOpen++
+		}
+	}
+	return -1, numOpen
+}
@@ -0,0 +1,121 @@
+package zenq_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+func TestSelectWrite(t *testing.T) {
+	zq1, zq2 := zenq.New[int](2), zenq.New[int](2)
+	defer zq1.Free()
+	defer zq2.Free()
+	if index, err := zenq.SelectWrite[int](1); index != -1 || err != zenq.ErrClosed {
+		t.Fatalf("wrote to %d out of no queues: %v", index, err)
+	}
+	if index, err := zenq.SelectWrite(1, nil, nil); index != -1 || err != zenq.ErrClosed {
+		t.Fatalf("wrote to %d out of nil queues: %v", index, err)
+	}
+
+	// every queue with room is written to
+	var written [2]int
+	for i := 0; i < 4; i++ {
+		index, err := zenq.TrySelectWrite(i, zq1, zq2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		written[index]++
+	}
+	if written != [2]int{2, 2} {
+		t.Fatalf("wrote %v", written)
+	}
+	if index, err := zenq.TrySelectWrite(9, zq1, zq2); index != -1 || err != zenq.ErrFull {
+		t.Fatalf("wrote to %d out of full queues: %v", index, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if index, err := zenq.SelectWriteContext(ctx, 9, zq1, zq2); index != -1 || !errors.Is(err, zenq.ErrTimeout) {
+		t.Fatalf("wrote to %d out of full queues: %v", index, err)
+	}
+
+	// a writer waiting for room is called once any queue has room
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		zq2.Read()
+	}()
+	if index, err := zenq.SelectWrite(9, zq1, nil, zq2); index != 2 || err != nil {
+		t.Fatalf("wrote to %d: %v", index, err)
+	}
+	// or else once every queue is closed
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		zq1.Close()
+		zq2.Close()
+	}()
+	if index, err := zenq.SelectWrite(10, zq1, zq2); index != -1 || err != zenq.ErrClosed {
+		t.Fatalf("wrote to %d out of closed queues: %v", index, err)
+	}
+}
+
+func TestSelectWriteWhileContending(t *testing.T) {
+	for _, ws := range []zenq.WaitStrategy{zenq.Blocking{}, zenq.Yielding{Spins: 4}} {
+		const shards, writers, perWriter = 4, 4, 3000
+		zqs := make([]*zenq.ZenQ[int], shards)
+		for i := range zqs {
+			zqs[i], _ = zenq.NewWithOptions[int](zenq.Options{Size: 8, WaitStrategy: ws})
+		}
+
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < perWriter; i++ {
+					if _, err := zenq.SelectWrite(w*perWriter+i, zqs...); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}(w)
+		}
+		read := make(chan map[int]bool, shards)
+		for _, zq := range zqs {
+			go func(zq *zenq.ZenQ[int]) {
+				seen := make(map[int]bool)
+				for {
+					item, queueOpen := zq.Read()
+					if !queueOpen {
+						break
+					}
+					seen[item] = true
+				}
+				read <- seen
+			}(zq)
+		}
+		wg.Wait()
+		for _, zq := range zqs {
+			zq.Close()
+		}
+
+		// every value is written to exactly one of the queues
+		all := make(map[int]bool)
+		for range zqs {
+			for item := range <-read {
+				if all[item] {
+					t.Fatalf("wrote %d twice", item)
+				}
+				all[item] = true
+			}
+		}
+		if len(all) != writers*perWriter {
+			t.Fatalf("wrote %d out of %d values", len(all), writers*perWriter)
+		}
+		for _, zq := range zqs {
+			zq.Free()
+		}
+	}
+}
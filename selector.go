@@ -1,6 +1,7 @@
 package zenq
 
 import (
+	"context"
 	"sync/atomic"
 	"unsafe"
 )
@@ -13,15 +14,32 @@ type Selectable interface {
 	Signal() uint8
 }
 
+// selectOptOut is implemented by streams which might have opted out of selections
+type selectOptOut interface {
+	noSelect() bool
+}
+
+// selectable returns whether the stream takes part in selections, nil streams and ZenQs created with NoSelect do not
+// as nothing would ever serve a selector waiting on them
+func selectable(stream Selectable) bool {
+	if stream == nil {
+		return false
+	}
+	optOut, canOptOut := stream.(selectOptOut)
+	return !canOptOut || !optOut.noSelect()
+}
+
 // Select selects a single element out of multiple ZenQs
 // A maximum of 127 ZenQs can be selected from at a time owing to the size of int8 type
 // `nil` is returned if all streams are closed or if a stream gets closed during the selection process
+// unless the stream was closed via CloseWithError() in which case an error matching both ErrClosed and the cause is returned
+// ZenQs created with NoSelect are skipped just like nil streams
 func Select(streams ...Selectable) (data any) {
 	numStreams := int8(len(streams) - 1)
 filter:
 	for idx := int8(0); idx < numStreams; idx++ {
-		if streams[idx] == nil || streams[idx].IsClosed() {
-			for ; numStreams >= 0 && (streams[numStreams] == nil || streams[numStreams].IsClosed()); numStreams-- {
+		if !selectable(streams[idx]) || streams[idx].IsClosed() {
+			for ; numStreams >= 0 && (!selectable(streams[numStreams]) || streams[numStreams].IsClosed()); numStreams-- {
 			}
 			if idx >= numStreams {
 				break filter
@@ -30,6 +48,10 @@ filter:
 			numStreams--
 		}
 	}
+	// the last stream is left to the selection even if closed, but not when nothing would ever serve it
+	if numStreams >= 0 && !selectable(streams[numStreams]) {
+		numStreams--
+	}
 	if numStreams < 0 {
 		data = nil
 		return
@@ -41,10 +63,10 @@ filter:
 		}
 	}
 
-	g, numSignals, iter := GetG(), uint8(0), int8(0)
+	sel, numSignals, attempt := newSelectorSpot(), uint8(0), uint32(0)
 
 	for idx := int8(0); idx <= numStreams; idx++ {
-		streams[idx].EnqueueSelector(&g, &data)
+		enqueueSelector(streams[idx], sel, &data)
 	}
 
 retry:
@@ -53,18 +75,238 @@ retry:
 	}
 
 	// might cause deadlock without this case
-	if numSignals == 0 && atomic.LoadPointer(&g) != nil {
+	if numSignals == 0 && atomic.LoadPointer(&sel.threadPtr) != nil {
 		// wait for some ZenQ to acquire this selector's thread
-		if runtime_canSpin(int(iter)) {
-			iter++
-			spin(30)
-		} else {
-			mcall(gosched_m)
-		}
+		backoff(adaptiveSpin{}, attempt)
+		attempt++
 		goto retry
 	}
 
 	// park and wait for notification
-	mcall(fast_park)
+	mcall(sel.park)
+	if closed, ok := data.(closedStream); ok {
+		data = closed.selection()
+	}
+	return
+}
+
+// SelectIndex selects a single element out of multiple ZenQs just like reflect.Select()
+// It returns the index of the stream selected along with the element read from it and ok as true, or else ok as false
+// along with a nil element in case the stream selected is closed, its cause is reported by Err() of the stream
+// Closed streams are selected right away whereas nil streams and ZenQs created with NoSelect are never selected,
+// hence -1 is returned only in case no stream is selectable, unlike Select() there is no limit on the number of streams and their order is left intact
+func SelectIndex(streams ...Selectable) (index int, value any, ok bool) {
+	if index, value, ok = poll(streams); index == -1 && value != nil {
+		return await(nil, streams)
+	}
+	return
+}
+
+// TrySelect selects a single element out of multiple ZenQs just like SelectIndex() but only in case it can be done
+// without waiting, otherwise -1 is returned
+// Elements are selected right away only once they were read ahead by the auxillary threads of their streams, hence
+// the streams are signalled to read ahead in case nothing could be selected so that a later call finds their elements
+func TrySelect(streams ...Selectable) (index int, value any, ok bool) {
+	if index, value, ok = poll(streams); index == -1 && value != nil {
+		readAhead(streams)
+		return -1, nil, false
+	}
+	return
+}
+
+// SelectContext selects a single element out of multiple ZenQs just like SelectIndex() but gives up waiting once ctx
+// is done, in which case the selector withdraws from every stream and -1 is returned
+// In case ctx is done already, it selects just like TrySelect()
+func SelectContext(ctx context.Context, streams ...Selectable) (index int, value any, ok bool) {
+	if index, value, ok = poll(streams); index == -1 && value != nil {
+		if ctx.Err() != nil {
+			// just like TrySelect() so that polling with a context done already makes progress
+			readAhead(streams)
+			return -1, nil, false
+		}
+		return await(cancellable(ctx), streams)
+	}
+	return
+}
+
+// poll selects a single element or a closed stream out of the given streams without waiting
+// In case there is nothing to select, -1 is returned along with a non-nil value unless no stream is selectable
+func poll(streams []Selectable) (index int, value any, ok bool) {
+	value = pendingSelection{}
+	for idx, stream := range streams {
+		if !selectable(stream) {
+			continue
+		}
+		// the elements read by a closed stream before it was closed are selected first
+		if data, read := readBacklog(stream); read {
+			return idx, data, true
+		} else if stream.IsClosed() {
+			return idx, nil, false
+		}
+		index = -1
+	}
+	if index == 0 {
+		// no stream is selectable
+		return -1, nil, false
+	}
 	return
 }
+
+// readAhead signals the given streams to read ahead so that their elements are found in their backlogs later on
+func readAhead(streams []Selectable) {
+	for _, stream := range streams {
+		if selectable(stream) {
+			stream.Signal()
+		}
+	}
+}
+
+// await waits for any of the given streams to serve the selector unless ctx is done beforehand
+// in which case the selector withdraws from every stream and -1 is returned
+// A callback is registered on a non-nil ctx just like in parkUntil()
+func await(ctx context.Context, streams []Selectable) (index int, value any, ok bool) {
+	// every stream hands over to its own slot so that the one which served the selector is known
+	sel, outs, attempt := newSelectorSpot(), make([]any, len(streams)), uint32(0)
+	// the selector might be served as soon as it is enqueued, hence its thread is kept aside for the callback
+	threadPtr := sel.threadPtr
+	var stop func() bool
+	if ctx != nil {
+		stop = watchContext(ctx, func() {
+			// the selector is served by no stream once it claims its own thread
+			if atomic.CompareAndSwapPointer(&sel.threadPtr, threadPtr, nil) {
+				readySelector(&sel.threadPtr, threadPtr, adaptiveSpin{}, true)
+			}
+		})
+	}
+	for idx, stream := range streams {
+		if selectable(stream) {
+			outs[idx] = pendingSelection{}
+			enqueueSelector(stream, sel, &outs[idx])
+		}
+	}
+	for {
+		numSignals := 0
+		for _, stream := range streams {
+			if selectable(stream) {
+				numSignals += int(stream.Signal())
+			}
+		}
+		if numSignals != 0 || atomic.LoadPointer(&sel.threadPtr) == nil {
+			break
+		}
+		// wait for some ZenQ to acquire this selector's thread
+		backoff(adaptiveSpin{}, attempt)
+		attempt++
+	}
+
+	// park and wait for notification
+	mcall(sel.park)
+	if stop != nil {
+		stop()
+	}
+	for idx, out := range outs {
+		switch out.(type) {
+		case pendingSelection:
+			// the stream did not serve the selector
+		case closedStream:
+			return idx, nil, false
+		default:
+			// the slots of streams which are not selectable remain nil whereas a stream might hand over a nil element
+			if out != nil || selectable(streams[idx]) {
+				return idx, out, true
+			}
+		}
+	}
+	// the selector withdrew, its entries still queued are skipped by the streams and pruned right away if possible
+	for _, stream := range streams {
+		if pruner, isPruner := stream.(selectorPruner); isPruner {
+			pruner.pruneSelectors()
+		}
+	}
+	return -1, nil, false
+}
+
+// selectorSpot is the thread of a selector along with whether it is parked already
+// Streams acquire a selector by swapping out its thread via the pointer enqueued in their waitlists which points to
+// this struct, the selector allocates while enqueueing which might park it for a GC assist meanwhile, hence the
+// streams wait for it to be flagged as parked rather than merely waiting before calling it
+type selectorSpot struct {
+	threadPtr unsafe.Pointer
+	parked    atomic.Bool
+	// park is allocated beforehand as the selector must not allocate once enqueued
+	park func(unsafe.Pointer)
+}
+
+// newSelectorSpot returns the thread of the calling selector
+func newSelectorSpot() *selectorSpot {
+	sel := &selectorSpot{threadPtr: GetG()}
+	sel.park = func(gp unsafe.Pointer) { flagged_park(gp, &sel.parked) }
+	return sel
+}
+
+// arm sets the thread of the spot to the one of the calling selector which waits on it afresh
+func (self *selectorSpot) arm() {
+	self.parked.Store(false)
+	atomic.StorePointer(&self.threadPtr, GetG())
+}
+
+// readySelector calls the selector acquired via the given thread pointer, once it is parked in case the pointer is
+// the one of a selectorSpot, otherwise right away as bare thread pointers were enqueued via EnqueueSelector()
+func readySelector(threadPtr *unsafe.Pointer, gp unsafe.Pointer, ws WaitStrategy, spot bool) {
+	if !spot {
+		safe_ready(gp, ws)
+		return
+	}
+	flagged_ready(gp, &(*selectorSpot)(unsafe.Pointer(threadPtr)).parked, ws)
+}
+
+// selectorSpotEnqueuer is implemented by streams which call the selectors enqueued with their spots only once parked
+type selectorSpotEnqueuer interface {
+	enqueueSelectorSpot(spot *selectorSpot, dataOut *any)
+}
+
+// enqueueSelector enqueues the selector waiting on the given spot in the waitlist of the stream, streams which know
+// nothing about selectorSpots are handed over its bare thread pointer via EnqueueSelector()
+func enqueueSelector(stream Selectable, spot *selectorSpot, dataOut *any) {
+	if enqueuer, isEnqueuer := stream.(selectorSpotEnqueuer); isEnqueuer {
+		enqueuer.enqueueSelectorSpot(spot, dataOut)
+		return
+	}
+	stream.EnqueueSelector(&spot.threadPtr, dataOut)
+}
+
+// pendingSelection marks the slot of a stream yet to serve a selector
+type pendingSelection struct{}
+
+// closedStream is handed over to a selector by a closed stream along with the cause it was closed with if any
+type closedStream struct {
+	cause error
+}
+
+// selection returns what Select() reports for a closed stream
+// which is the cause in case it was closed via CloseWithError() and nil otherwise
+func (self closedStream) selection() any {
+	if self.cause != nil {
+		return causedError{ErrClosed, self.cause}
+	}
+	return nil
+}
+
+// selectorPruner is implemented by streams able to drop the selectors which withdrew from their waitlists
+type selectorPruner interface {
+	pruneSelectors()
+}
+
+// backlogReader is implemented by streams telling a nil element apart from an empty backlog
+type backlogReader interface {
+	readBacklog() (data any, ok bool)
+}
+
+// readBacklog reads an element from the backlog of the given stream if available
+func readBacklog(stream Selectable) (data any, ok bool) {
+	if reader, isReader := stream.(backlogReader); isReader {
+		return reader.readBacklog()
+	}
+	data = stream.ReadFromBackLog()
+	return data, data != nil
+}
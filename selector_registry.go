@@ -0,0 +1,284 @@
+package zenq
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Selector selects out of a set of streams it stays registered with for as long as they are members of the set
+// Unlike Select() which enqueues the selector in the waitlist of every stream on every call, the streams hand over to
+// the registered selector whenever it waits, hence selecting allocates nothing and there is no limit on the number
+// of streams, streams can be added and removed at any time including while a selection is underway
+// Streams other than ZenQs which cannot keep a registration are enqueued on every call just like in Select()
+type Selector struct {
+	waiter *selectorWaiter
+	// copied on every change so that it is iterated without locks
+	members atomic.Pointer[[]*selectorEntry]
+	// held while adding or removing a stream so that its registration follows its membership
+	mutex sync.Mutex
+	// held for the duration of a selection as the selector waits on a single spot
+	selecting sync.Mutex
+	// the position the streams are polled from which moves on every call so that no stream starves the others
+	offset int
+}
+
+// NewSelector returns a selector over the given streams, nil streams and ZenQs created with NoSelect are ignored
+func NewSelector(streams ...Selectable) *Selector {
+	self := &Selector{waiter: newSelectorWaiter()}
+	for _, stream := range streams {
+		self.Add(stream)
+	}
+	return self
+}
+
+// Add adds a stream to the set of streams selected from unless it is not selectable or a member already
+func (self *Selector) Add(stream Selectable) {
+	if !selectable(stream) {
+		return
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	entries := self.entries()
+	for _, entry := range entries {
+		if entry.stream == stream {
+			return
+		}
+	}
+	registrar, isRegistrar := stream.(selectorRegistrar)
+	entries = append(entries[:len(entries):len(entries)], &selectorEntry{stream: stream, registered: isRegistrar})
+	self.members.Store(&entries)
+	if isRegistrar {
+		registrar.registry().add(self.waiter)
+	}
+	// a selection underway must find the stream reading ahead just like the streams signalled beforehand, otherwise
+	// the stream is left alone so that its plain reads keep getting the elements in order
+	if atomic.LoadPointer(&self.waiter.threadPtr) != nil {
+		stream.Signal()
+	}
+}
+
+// Remove removes a stream from the set of streams selected from
+// An element the stream is handing over to a selection underway meanwhile is still selected, otherwise the selection
+// polls the streams left once again, hence it returns right away in case the set turned empty
+func (self *Selector) Remove(stream Selectable) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	entries := self.entries()
+	for idx, entry := range entries {
+		if entry.stream == stream {
+			kept := append(append(make([]*selectorEntry, 0, len(entries)-1), entries[:idx]...), entries[idx+1:]...)
+			self.members.Store(&kept)
+			if entry.registered {
+				stream.(selectorRegistrar).registry().remove(self.waiter)
+			}
+			// the selector is served by no stream once it is acquired here
+			if gp := atomic.SwapPointer(&self.waiter.threadPtr, nil); gp != nil {
+				readySelector(&self.waiter.threadPtr, gp, adaptiveSpin{}, true)
+			}
+			return
+		}
+	}
+}
+
+// Len returns the number of streams selected from
+func (self *Selector) Len() int {
+	return len(self.entries())
+}
+
+// entries returns the members of the set at the moment
+func (self *Selector) entries() []*selectorEntry {
+	if entries := self.members.Load(); entries != nil {
+		return *entries
+	}
+	return nil
+}
+
+// Select selects a single element out of the streams of the set just like SelectIndex() but returns the stream
+// selected instead of its index, a nil stream is returned only in case the set is empty
+// Selections on the same selector are serialized as the selector waits on a single spot
+func (self *Selector) Select() (stream Selectable, value any, ok bool) {
+	self.selecting.Lock()
+	defer self.selecting.Unlock()
+	for {
+		entries := self.entries()
+		if entry, value, ok := self.poll(entries); entry != nil || value == nil {
+			return entry.streamOrNil(), value, ok
+		}
+		self.waiter.arm()
+		for _, entry := range entries {
+			if !entry.registered {
+				entry.out = pendingSelection{}
+				enqueueSelector(entry.stream, &self.waiter.selectorSpot, &entry.out)
+			}
+		}
+		// the streams holding no element read ahead whereas the ones parked with an element in their backlogs take it
+		// back and hand it over, the ones reading already check for the selector before they park
+		// The members are loaded again once armed, hence a stream added meanwhile is signalled here or by Add()
+		for _, entry := range self.entries() {
+			entry.stream.Signal()
+		}
+
+		// park and wait for notification
+		mcall(self.waiter.park)
+		// the stream which served the selector is dropped right away so that the waiter never keeps it reachable
+		servedBy, out := self.waiter.servedBy, self.waiter.out
+		self.waiter.servedBy, self.waiter.out = nil, nil
+		if servedBy != nil {
+			return selection(servedBy, out)
+		}
+		for _, entry := range entries {
+			if _, pending := entry.out.(pendingSelection); !entry.registered && !pending {
+				return selection(entry.stream, entry.out)
+			}
+		}
+	}
+}
+
+// TrySelect selects a single element out of the streams of the set just like Select() but only in case it can be
+// done without waiting, otherwise a nil stream is returned and the streams are signalled to read ahead just like in
+// the TrySelect() function
+func (self *Selector) TrySelect() (stream Selectable, value any, ok bool) {
+	self.selecting.Lock()
+	defer self.selecting.Unlock()
+	entries := self.entries()
+	if entry, value, ok := self.poll(entries); entry != nil || value == nil {
+		return entry.streamOrNil(), value, ok
+	}
+	for _, entry := range entries {
+		entry.stream.Signal()
+	}
+	return nil, nil, false
+}
+
+// poll selects a single element or a closed stream out of the given entries without waiting just like poll()
+// In case there is nothing to select, a nil entry is returned along with a non-nil value unless there are no entries
+func (self *Selector) poll(entries []*selectorEntry) (entry *selectorEntry, value any, ok bool) {
+	if len(entries) == 0 {
+		return nil, nil, false
+	}
+	self.offset++
+	for n := range entries {
+		entry = entries[(self.offset+n)%len(entries)]
+		// the elements read by a closed stream before it was closed are selected first
+		if data, read := readBacklog(entry.stream); read {
+			return entry, data, true
+		} else if entry.stream.IsClosed() {
+			return entry, nil, false
+		}
+	}
+	return nil, pendingSelection{}, false
+}
+
+// selectorEntry is the membership of a stream in the set of a Selector
+type selectorEntry struct {
+	stream Selectable
+	// whether the selector is registered with the stream, otherwise it is enqueued on every selection
+	// and the stream hands over to out
+	registered bool
+	out        any
+}
+
+// streamOrNil returns the stream of the entry or nil in case the entry is nil
+func (self *selectorEntry) streamOrNil() Selectable {
+	if self == nil {
+		return nil
+	}
+	return self.stream
+}
+
+// selection returns what Select() of a Selector reports for an element handed over by the given stream
+func selection(stream Selectable, value any) (Selectable, any, bool) {
+	if _, closed := value.(closedStream); closed {
+		return stream, nil, false
+	}
+	return stream, value, true
+}
+
+// selectorWaiter is the spot a Selector waits on which is all the streams it is registered with refer to
+// It refers to no stream except for the one which served it until the selector takes the element handed over,
+// as a stream reachable from itself via its registry would never be finalized
+type selectorWaiter struct {
+	selectorSpot
+	// the stream which served the selector along with the element it handed over
+	servedBy Selectable
+	out      any
+}
+
+// newSelectorWaiter returns a waiter yet to be armed by the selector waiting on it
+func newSelectorWaiter() *selectorWaiter {
+	self := new(selectorWaiter)
+	self.park = func(gp unsafe.Pointer) { flagged_park(gp, &self.parked) }
+	return self
+}
+
+// handOver hands an element of the given stream over to the selector whose thread was acquired and calls it
+func (self *selectorWaiter) handOver(stream Selectable, value any, gp unsafe.Pointer, ws WaitStrategy) {
+	self.servedBy, self.out = stream, value
+	readySelector(&self.threadPtr, gp, ws, true)
+}
+
+// selectorRegistrar is implemented by streams able to keep the registrations of selectors
+type selectorRegistrar interface {
+	registry() *selectorRegistry
+}
+
+// selectorRegistry is a set of selector waiters which is copied on every change so that it is iterated without locks
+type selectorRegistry struct {
+	mutex   sync.Mutex
+	waiters atomic.Pointer[[]*selectorWaiter]
+}
+
+// load returns the waiters of the registry at the moment
+func (self *selectorRegistry) load() []*selectorWaiter {
+	if waiters := self.waiters.Load(); waiters != nil {
+		return *waiters
+	}
+	return nil
+}
+
+// add adds a waiter to the registry
+func (self *selectorRegistry) add(waiter *selectorWaiter) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	waiters := self.load()
+	waiters = append(waiters[:len(waiters):len(waiters)], waiter)
+	self.waiters.Store(&waiters)
+}
+
+// remove removes a waiter from the registry
+func (self *selectorRegistry) remove(waiter *selectorWaiter) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	waiters := self.load()
+	kept := make([]*selectorWaiter, 0, len(waiters))
+	for _, w := range waiters {
+		if w != waiter {
+			kept = append(kept, w)
+		}
+	}
+	self.waiters.Store(&kept)
+}
+
+// waiting returns whether any selector registered is waiting at the moment
+func (self *selectorRegistry) waiting() bool {
+	for _, waiter := range self.load() {
+		if atomic.LoadPointer(&waiter.threadPtr) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire acquires the thread of any selector registered which is waiting at the moment
+// It returns a nil thread in case none is waiting
+func (self *selectorRegistry) acquire() (waiter *selectorWaiter, gp unsafe.Pointer) {
+	for _, waiter = range self.load() {
+		if atomic.LoadPointer(&waiter.threadPtr) != nil {
+			if gp = atomic.SwapPointer(&waiter.threadPtr, nil); gp != nil {
+				return
+			}
+		}
+	}
+	return nil, nil
+}
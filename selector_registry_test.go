@@ -0,0 +1,320 @@
+package zenq_test
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+func TestSelector(t *testing.T) {
+	zqs := []*zenq.ZenQ[int]{zenq.New[int](8), zenq.New[int](8), zenq.New[int](8)}
+	selector := zenq.NewSelector(zqs[0], zqs[1], nil, zqs[2], zqs[1])
+	if n := selector.Len(); n != 3 {
+		t.Fatalf("selector over %d streams", n)
+	}
+
+	for i, zq := range zqs {
+		zq.Write(i)
+	}
+	seen := make(map[any]bool)
+	for i := 0; i < len(zqs); i++ {
+		stream, value, ok := selector.Select()
+		if !ok || seen[value] || stream != zenq.Selectable(zqs[value.(int)]) {
+			t.Fatalf("selected %v from %v, ok %t", value, stream, ok)
+		}
+		seen[value] = true
+	}
+
+	// elements written while waiting are selected from the stream they were written to
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		zqs[2].Write(42)
+	}()
+	if stream, value, ok := selector.Select(); !ok || value != 42 || stream != zenq.Selectable(zqs[2]) {
+		t.Fatalf("selected %v from %v, ok %t", value, stream, ok)
+	}
+
+	// and so are the elements of streams added while waiting
+	added := zenq.New[int](4)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		selector.Add(added)
+		added.Write(7)
+	}()
+	if stream, value, ok := selector.Select(); !ok || value != 7 || stream != zenq.Selectable(added) {
+		t.Fatalf("selected %v from %v, ok %t", value, stream, ok)
+	}
+
+	zqs[0].CloseWithError(errors.New("closed"))
+	if stream, value, ok := selector.Select(); ok || value != nil || stream != zenq.Selectable(zqs[0]) {
+		t.Fatalf("selected %v from %v, ok %t", value, stream, ok)
+	}
+	selector.Remove(zqs[0])
+	selector.Remove(zqs[0])
+	if n := selector.Len(); n != 3 {
+		t.Fatalf("selector over %d streams", n)
+	}
+	if stream, _, _ := selector.TrySelect(); stream != nil {
+		t.Fatalf("selected from %v without any element written", stream)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		zqs[1].Free()
+	}()
+	if stream, value, ok := selector.Select(); ok || value != nil || stream != zenq.Selectable(zqs[1]) {
+		t.Fatalf("selected %v from %v, ok %t", value, stream, ok)
+	}
+
+	if stream, value, ok := zenq.NewSelector().Select(); stream != nil || value != nil || ok {
+		t.Fatalf("selected %v from %v out of an empty set, ok %t", value, stream, ok)
+	}
+}
+
+func TestSelectorManyStreams(t *testing.T) {
+	const numQueues, perQueue, writers = 3000, 20, 8
+	zqs := make([]*zenq.ZenQ[int], numQueues)
+	selector := zenq.NewSelector()
+	for i := range zqs {
+		zqs[i] = zenq.New[int](4)
+		selector.Add(zqs[i])
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < numQueues; i += writers {
+				for j := 0; j < perQueue; j++ {
+					zqs[i].Write(i*perQueue + j)
+				}
+			}
+		}(w)
+	}
+	seen := make([]bool, numQueues*perQueue)
+	for i := 0; i < numQueues*perQueue; i++ {
+		stream, value, ok := selector.Select()
+		if !ok || seen[value.(int)] || stream != zenq.Selectable(zqs[value.(int)/perQueue]) {
+			t.Fatalf("selected %v from %v, ok %t", value, stream, ok)
+		}
+		seen[value.(int)] = true
+	}
+	wg.Wait()
+	if stream, value, _ := selector.TrySelect(); stream != nil {
+		t.Fatalf("selected %v once every element was selected", value)
+	}
+	for _, zq := range zqs {
+		selector.Remove(zq)
+		zq.Free()
+	}
+}
+
+func TestSelectorAddRemoveWhileSelecting(t *testing.T) {
+	const total = 5000
+	zqs := make([]zenq.Selectable, 50)
+	for i := range zqs {
+		zqs[i] = zenq.New[int](4)
+	}
+	selector := zenq.NewSelector()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			selector.Add(zqs[i%len(zqs)])
+			selector.Remove(zqs[i*7%len(zqs)])
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < total; i++ {
+			zqs[i%len(zqs)].(*zenq.ZenQ[int]).Write(i)
+		}
+	}()
+
+	// every element is selected exactly once, the ones of streams removed meanwhile by selecting from all the streams
+	seen := make([]bool, total)
+	for selected, deadline := 0, time.Now().Add(60*time.Second); selected < total; selected++ {
+		var value any
+		for {
+			if time.Now().After(deadline) {
+				t.Fatalf("selected %d out of %d elements", selected, total)
+			}
+			var stream zenq.Selectable
+			if stream, value, _ = selector.TrySelect(); stream != nil {
+				break
+			}
+			if index, data, _ := zenq.TrySelect(zqs...); index >= 0 {
+				value = data
+				break
+			}
+		}
+		if seen[value.(int)] {
+			t.Fatalf("selected %v twice", value)
+		}
+		seen[value.(int)] = true
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestSelectorAllocatesNothing(t *testing.T) {
+	type item struct{ a, b int }
+	zq1, zq2 := zenq.New[*item](16), zenq.New[*item](16)
+	selector := zenq.NewSelector(zq1, zq2)
+
+	written, done := &item{1, 2}, make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			zq1.Write(written)
+		}
+	}()
+	allocs := testing.AllocsPerRun(2000, func() {
+		if _, value, ok := selector.Select(); !ok || value != written {
+			t.Fatalf("selected %v, ok %t", value, ok)
+		}
+	})
+	close(done)
+	selector.Select()
+	if allocs > 0.5 {
+		t.Fatalf("%.2f allocations per selection", allocs)
+	}
+}
+
+func TestSelectorKeepsNoQueueFromBeingFinalized(t *testing.T) {
+	const numQueues = 50
+	runtime.GC()
+	time.Sleep(10 * time.Millisecond)
+	goroutines := runtime.NumGoroutine()
+
+	func() {
+		selector := zenq.NewSelector()
+		for i := 0; i < numQueues; i++ {
+			zq := zenq.New[int](4)
+			selector.Add(zq)
+			zq.Write(i)
+		}
+		for i := 0; i < numQueues; i++ {
+			if _, _, ok := selector.Select(); !ok {
+				t.Fatal("selected a closed queue")
+			}
+		}
+	}()
+
+	// every queue stops its auxiliary goroutine once finalized
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); {
+		runtime.GC()
+		if runtime.NumGoroutine() <= goroutines {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%d goroutines left behind by %d unreachable queues", runtime.NumGoroutine()-goroutines, numQueues)
+}
+
+func TestSelectorRemoveWhileSelecting(t *testing.T) {
+	zq1, zq2 := zenq.New[int](4), zenq.New[int](4)
+	selector := zenq.NewSelector(zq1, zq2)
+
+	type selection struct {
+		stream zenq.Selectable
+		value  any
+		ok     bool
+	}
+	selected := make(chan selection)
+	selectAsync := func() {
+		go func() {
+			stream, value, ok := selector.Select()
+			selected <- selection{stream, value, ok}
+		}()
+		time.Sleep(10 * time.Millisecond)
+	}
+	await := func() selection {
+		select {
+		case s := <-selected:
+			return s
+		case <-time.After(5 * time.Second):
+			t.Fatal("selection still waiting")
+		}
+		return selection{}
+	}
+
+	// the selection underway keeps waiting on the streams left
+	selectAsync()
+	selector.Remove(zq1)
+	zq1.Write(1)
+	zq2.Write(2)
+	if s := await(); s.stream != zenq.Selectable(zq2) || s.value != 2 || !s.ok {
+		t.Fatalf("selected %v from %v, ok %t", s.value, s.stream, s.ok)
+	}
+
+	// and returns once the set turns empty
+	selectAsync()
+	selector.Remove(zq2)
+	if s := await(); s.stream != nil || s.value != nil || s.ok {
+		t.Fatalf("selected %v from %v, ok %t", s.value, s.stream, s.ok)
+	}
+}
+
+func TestSelectorAddKeepsReadsInOrder(t *testing.T) {
+	zq := zenq.New[int](8)
+	defer zq.Free()
+	selector := zenq.NewSelector()
+	// the auxillary thread of the queue parks until signalled
+	time.Sleep(10 * time.Millisecond)
+	selector.Add(zq)
+	for i := 0; i < 4; i++ {
+		zq.Write(i)
+	}
+	// nobody selects hence the queue reads nothing ahead of the plain reads
+	time.Sleep(10 * time.Millisecond)
+	if item, ok := zq.Peek(); !ok || item != 0 {
+		t.Fatalf("peeked %d, ok %t", item, ok)
+	}
+	if item, ok, err := zq.TryRead(); !ok || err != nil || item != 0 {
+		t.Fatalf("read %d, ok %t, err %v", item, ok, err)
+	}
+	if items := make([]int, 2); func() int { n, _ := zq.ReadBatch(items); return n }() != 2 || items[0] != 1 || items[1] != 2 {
+		t.Fatalf("read %v", items)
+	}
+	if item, queueOpen := zq.Read(); !queueOpen || item != 3 {
+		t.Fatalf("read %d, open %t", item, queueOpen)
+	}
+
+	// a stream added while selecting is read ahead for the selection
+	other := zenq.New[int](8)
+	defer other.Free()
+	selected := make(chan any)
+	go func() {
+		_, value, _ := selector.Select()
+		selected <- value
+	}()
+	time.Sleep(10 * time.Millisecond)
+	selector.Add(other)
+	other.Write(5)
+	select {
+	case value := <-selected:
+		if value != 5 {
+			t.Fatalf("selected %v", value)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("selection still waiting")
+	}
+}
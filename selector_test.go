@@ -0,0 +1,271 @@
+package zenq_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+func TestEnqueueSelectorWithBareThreadPointer(t *testing.T) {
+	zq := zenq.New[int](4)
+
+	selected := make(chan any)
+	for i := 0; i < 2; i++ {
+		go func() {
+			// a selector implemented outside of this package knows nothing about the spots of the selectors within
+			var data any
+			thread := zenq.GetG()
+			zq.EnqueueSelector(&thread, &data)
+			zq.Signal()
+			zenq.ParkSelector()
+			selected <- data
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	zq.Write(1)
+	zq.Write(2)
+
+	sum := 0
+	for i := 0; i < 2; i++ {
+		select {
+		case data := <-selected:
+			sum += data.(int)
+		case <-time.After(5 * time.Second):
+			t.Fatal("selector never called")
+		}
+	}
+	if sum != 3 {
+		t.Fatalf("selected a sum of %d", sum)
+	}
+}
+
+func TestSelectContextCancelledWhileWaiting(t *testing.T) {
+	zq1, zq2 := zenq.New[int](4), zenq.New[int](4)
+	time.Sleep(10 * time.Millisecond)
+	goroutines := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	selected := make(chan int)
+	go func() {
+		index, _, _ := zenq.SelectContext(ctx, zq1, zq2)
+		selected <- index
+	}()
+	time.Sleep(10 * time.Millisecond)
+	// waiting on a context spawns no goroutine besides the selecting one
+	if n := runtime.NumGoroutine(); n > goroutines+1 {
+		t.Fatalf("%d goroutines while selecting, %d beforehand", n, goroutines)
+	}
+	cancel()
+	select {
+	case index := <-selected:
+		if index != -1 {
+			t.Fatalf("selected %d", index)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("selector not called once cancelled")
+	}
+
+	// the selector withdrew, hence nothing written afterwards is lost to it
+	zq2.Write(5)
+	if index, value, ok := zenq.SelectIndex(zq1, zq2); index != 1 || value != 5 || !ok {
+		t.Fatalf("selected %v from %d, ok %t", value, index, ok)
+	}
+}
+
+func TestSelectIndex(t *testing.T) {
+	ints, anys, pointers := zenq.New[int](4), zenq.New[any](4), zenq.New[*int](4)
+	defer ints.Free()
+	if index, value, ok := zenq.SelectIndex(); index != -1 || value != nil || ok {
+		t.Fatalf("selected %v from %d out of no streams, ok %t", value, index, ok)
+	}
+	if index, _, _ := zenq.SelectIndex(nil, nil); index != -1 {
+		t.Fatalf("selected from %d out of nil streams", index)
+	}
+
+	// nil elements are told apart from closed streams
+	anys.Write(nil)
+	if index, value, ok := zenq.SelectIndex(nil, ints, anys); index != 2 || value != nil || !ok {
+		t.Fatalf("selected %v from %d, ok %t", value, index, ok)
+	}
+	pointers.Write(nil)
+	if index, value, ok := zenq.SelectIndex(ints, anys, pointers); index != 2 || value.(*int) != nil || !ok {
+		t.Fatalf("selected %v from %d, ok %t", value, index, ok)
+	}
+	ints.Write(5)
+	if index, value, ok := zenq.SelectIndex(anys, pointers, ints); index != 2 || value != 5 || !ok {
+		t.Fatalf("selected %v from %d, ok %t", value, index, ok)
+	}
+	anys.CloseWithError(errors.New("closed"))
+	if index, value, ok := zenq.SelectIndex(ints, pointers, anys); index != 2 || value != nil || ok {
+		t.Fatalf("selected %v from %d, ok %t", value, index, ok)
+	}
+	// whereas Select() still reports the cause
+	if data := zenq.Select(anys); !errors.Is(data.(error), zenq.ErrClosed) {
+		t.Fatalf("selected %v", data)
+	}
+
+	// streams closing or getting nil elements while the selection waits
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		pointers.Close()
+	}()
+	if index, value, ok := zenq.SelectIndex(nil, ints, pointers); index != 2 || value != nil || ok {
+		t.Fatalf("selected %v from %d, ok %t", value, index, ok)
+	}
+	nils := zenq.New[any](4)
+	defer nils.Free()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		nils.Write(nil)
+	}()
+	if index, value, ok := zenq.SelectIndex(ints, nil, nils); index != 2 || value != nil || !ok {
+		t.Fatalf("selected %v from %d, ok %t", value, index, ok)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		ints.Write(7)
+	}()
+	if index, value, ok := zenq.SelectIndex(nils, nil, ints); index != 2 || value != 7 || !ok {
+		t.Fatalf("selected %v from %d, ok %t", value, index, ok)
+	}
+}
+
+func TestSelectIndexManyStreams(t *testing.T) {
+	const numQueues, perQueue = 200, 50
+	streams := make([]zenq.Selectable, numQueues)
+	zqs := make([]*zenq.ZenQ[int], numQueues)
+	for i := range zqs {
+		zqs[i] = zenq.New[int](8)
+		streams[i] = zqs[i]
+	}
+	var wg sync.WaitGroup
+	for i := range zqs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perQueue; j++ {
+				zqs[i].Write(i*perQueue + j)
+			}
+			zqs[i].Close()
+		}(i)
+	}
+
+	// every closed stream is replaced by nil until none are left
+	seen := make(map[int]bool)
+	for open := numQueues; open > 0; {
+		index, value, ok := zenq.SelectIndex(streams...)
+		if index < 0 {
+			t.Fatal("selected no stream")
+		} else if !ok {
+			if streams[index] == nil {
+				t.Fatalf("selected nil stream %d", index)
+			}
+			streams[index] = nil
+			open--
+			continue
+		}
+		if value.(int)/perQueue != index || seen[value.(int)] {
+			t.Fatalf("selected %v from %d", value, index)
+		}
+		seen[value.(int)] = true
+	}
+	wg.Wait()
+	if len(seen) != numQueues*perQueue {
+		t.Fatalf("selected %d out of %d elements", len(seen), numQueues*perQueue)
+	}
+	for _, zq := range zqs {
+		zq.Free()
+	}
+}
+
+func TestTrySelect(t *testing.T) {
+	zq1, zq2 := zenq.New[int](4), zenq.New[int](4)
+	defer zq1.Free()
+	defer zq2.Free()
+	if index, _, _ := zenq.TrySelect(zq1, zq2); index != -1 {
+		t.Fatalf("selected from %d without any element written", index)
+	}
+	if index, _, _ := zenq.TrySelect(nil); index != -1 {
+		t.Fatalf("selected from nil stream %d", index)
+	}
+
+	// the elements are read ahead by the auxiliary goroutines signalled by the calls which found nothing to select
+	deadline := time.Now().Add(5 * time.Second)
+	zq2.Write(3)
+	for {
+		index, value, ok := zenq.TrySelect(zq1, zq2)
+		if index == 1 && value == 3 && ok {
+			break
+		} else if index != -1 || time.Now().After(deadline) {
+			t.Fatalf("selected %v from %d, ok %t", value, index, ok)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	zq1.Close()
+	for {
+		index, _, ok := zenq.TrySelect(zq2, zq1)
+		if index == 1 && !ok {
+			break
+		} else if index != -1 || time.Now().After(deadline) {
+			t.Fatalf("selected from %d, ok %t", index, ok)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSelectContext(t *testing.T) {
+	zq1, zq2 := zenq.New[int](4), zenq.New[int](4)
+	defer zq1.Free()
+	defer zq2.Free()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if index, value, ok := zenq.SelectContext(ctx, zq1, zq2); index != -1 || value != nil || ok {
+		t.Fatalf("selected %v from %d, ok %t", value, index, ok)
+	} else if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Fatalf("gave up after %v", elapsed)
+	}
+	if index, _, _ := zenq.SelectContext(ctx, zq1); index != -1 {
+		t.Fatalf("selected from %d with a context done already", index)
+	}
+
+	// the selections given up on consume nothing
+	zq1.Write(1)
+	if index, value, ok := zenq.SelectIndex(zq1, zq2); index != 0 || value != 1 || !ok {
+		t.Fatalf("selected %v from %d, ok %t", value, index, ok)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		zq2.Write(2)
+	}()
+	if index, value, ok := zenq.SelectContext(context.Background(), zq1, zq2); index != 1 || value != 2 || !ok {
+		t.Fatalf("selected %v from %d, ok %t", value, index, ok)
+	}
+
+	// cancellations racing the elements neither lose nor duplicate any of them
+	const numItems = 500
+	go func() {
+		for i := 0; i < numItems; i++ {
+			zq1.Write(i)
+		}
+	}()
+	seen := make(map[int]bool)
+	for len(seen) < numItems {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(1+len(seen)%3)*20*time.Microsecond)
+		if index, value, ok := zenq.SelectContext(ctx, zq2, zq1); index == 1 {
+			if !ok || seen[value.(int)] {
+				t.Fatalf("selected %v, ok %t", value, ok)
+			}
+			seen[value.(int)] = true
+		} else if index != -1 {
+			t.Fatalf("selected from %d", index)
+		}
+		cancel()
+	}
+}
@@ -1,40 +1,94 @@
 package zenq
 
 import (
+	"context"
+	"sync"
 	"sync/atomic"
 	"unsafe"
 )
 
+// waitSpot cancellation state enums
+const (
+	// the parked goroutine is still waiting to be called
+	spotWaiting = iota
+	// the parked goroutine was called by ready()
+	spotClaimed
+	// the parked goroutine gave up waiting and will not be called anymore
+	spotCancelled
+	// the parked goroutine was called by ready() after its value was handed over
+	spotServed
+)
+
 // ThreadParker is a data-structure used for sleeping and waking up goroutines on user call
 // useful for saving up resources by parking excess goroutines and pre-empt them when required with minimal latency overhead
-// Uses the same lock-free linked list implementation as in `list.go`
+// Uses the same lock-free linked list implementation as in `list.go` for parking, whereas goroutines are called
+// in the order of the turns they wait for rather than in the order they parked, hence spots are removed under a lock
+// The zero value is ready to use, its sentinel spot is allocated only once a goroutine parks on it for the first time
 type ThreadParker[T any] struct {
-	head atomic.Pointer[parkSpot[T]]
-	tail atomic.Pointer[parkSpot[T]]
+	head atomic.Pointer[waitSpot]
+	tail atomic.Pointer[waitSpot]
+	// remover serializes the removals of spots which might lie anywhere in the list
+	remover sync.Mutex
 }
 
 // NewThreadParker returns a new thread parker.
+// A nil spot returns the zero value which allocates its sentinel spot lazily
 func NewThreadParker[T any](spot *parkSpot[T]) *ThreadParker[T] {
-	var ptr atomic.Pointer[parkSpot[T]]
-	ptr.Store(spot)
+	if spot == nil {
+		return new(ThreadParker[T])
+	}
+	var ptr atomic.Pointer[waitSpot]
+	ptr.Store(&spot.waitSpot)
 	return &ThreadParker[T]{head: ptr, tail: ptr}
 }
 
-// a single parked goroutine
+// a single parked goroutine along with the value it hands over
 type parkSpot[T any] struct {
-	next      atomic.Pointer[parkSpot[T]]
+	waitSpot
+	value T
+}
+
+// a single goroutine waiting on a parker
+// Spots carry no value so that waiting allocates nothing of the size of T, only the spots of goroutines waiting to hand
+// over a value are allocated as part of a parkSpot
+type waitSpot struct {
+	next      atomic.Pointer[waitSpot]
 	threadPtr unsafe.Pointer
-	value     T
+	// cancel is owned by the parked goroutine so that it remains valid even after the spot is removed
+	cancel *parking
+	// the strategy for waiting until the goroutine is actually parked before calling it
+	waitStrategy WaitStrategy
+	// the turn the goroutine is waiting for, the goroutines waiting for later turns are left parked
+	idx uint32
+	// whether the spot is the first field of a parkSpot
+	handoff bool
+}
+
+// the cancellation state of a parked goroutine along with whether it is actually parked already
+type parking struct {
+	atomic.Uint32
+	parked atomic.Bool
 }
 
 // Park parks the current calling goroutine
 // This keeps only one parked goroutine in state at all times
 // the parked goroutine is called with minimal overhead via goready() due to both being in userland
 // This ensures there is no thundering herd https://en.wikipedia.org/wiki/Thundering_herd_problem
+// The spot merely carries the thread of the goroutine along with the value handed over to Ready(), the goroutine
+// parks itself right after
 func (tp *ThreadParker[T]) Park(nextNode *parkSpot[T]) {
-	var tail, next *parkSpot[T]
+	nextNode.cancel, nextNode.handoff = new(parking), true
+	tp.park(&nextNode.waitSpot)
+}
+
+// park appends the given spot to the queue
+func (tp *ThreadParker[T]) park(nextNode *waitSpot) {
+	var tail, next *waitSpot
 	for {
-		tail = tp.tail.Load()
+		if tail = tp.tail.Load(); tail == nil {
+			tp.init()
+			continue
+		}
 		next = tail.next.Load()
 		if tail == tp.tail.Load() {
 			if next == nil {
@@ -49,29 +103,220 @@ func (tp *ThreadParker[T]) Park(nextNode *parkSpot[T]) {
 	}
 }
 
+// init allocates the sentinel spot of a parker nobody parked on so far
+func (tp *ThreadParker[T]) init() {
+	tp.head.CompareAndSwap(nil, new(waitSpot))
+	tp.tail.CompareAndSwap(nil, tp.head.Load())
+}
+
+// idle returns whether there are no parked goroutines at the moment
+func (tp *ThreadParker[T]) idle() bool {
+	head := tp.head.Load()
+	return head == nil || head.next.Load() == nil
+}
+
 // Ready calls one parked goroutine from the queue if available
+// It returns the value handed over by the goroutine via Park() along with ok = true in case one was called,
+// freeable is its spot in case it was enqueued via Park() and the parker holds no reference to it anymore
 func (tp *ThreadParker[T]) Ready() (data T, ok bool, freeable *parkSpot[T]) {
-	var head, tail, next *parkSpot[T]
+	spot, _, unlinked := tp.take(0, true, false)
+	if spot == nil {
+		return
+	}
+	if spot.handoff {
+		parked := (*parkSpot[T])(unsafe.Pointer(spot))
+		if data = parked.value; unlinked {
+			freeable = parked
+		}
+	}
+	wake(spot.threadPtr, spot.cancel, spot.waitStrategy)
+	return data, true, freeable
+}
+
+// ready calls one goroutine parked for the given turn or an earlier one, the goroutines waiting for later turns are
+// left parked as nothing they wait for occurred yet
+// In case the goroutine was waiting for the given turn with a value to hand over, it is handed over to commit
+// before calling it unless commit is nil, in case commit refuses the value the goroutine is called without being served
+// It returns false once there is no such goroutine left
+func (tp *ThreadParker[T]) ready(turn uint32, commit func(T) bool) bool {
+	spot, served, _ := tp.take(turn, false, commit != nil)
+	if spot == nil {
+		return false
+	}
+	if served && !commit((*parkSpot[T])(unsafe.Pointer(spot)).value) {
+		// the goroutine is parked or about to be, hence it finds the spot claimed once called
+		spot.cancel.Store(spotClaimed)
+	}
+	wake(spot.threadPtr, spot.cancel, spot.waitStrategy)
+	return true
+}
+
+// readyAll calls every parked goroutine without handing over any values
+func (tp *ThreadParker[T]) readyAll() {
 	for {
-		head = tp.head.Load()
-		tail = tp.tail.Load()
-		next = head.next.Load()
-		if head == tp.head.Load() {
-			if head == tail {
-				if next == nil {
-					return
-				}
-				tp.tail.CompareAndSwap(tail, next)
-			} else {
-				safe_ready(next.threadPtr)
-				data, ok = next.value, true
-				if tp.head.CompareAndSwap(head, next) {
-					freeable = head
-					freeable.threadPtr = nil
-					freeable.next.Store(nil)
-					return
-				}
+		spot, _, _ := tp.take(0, true, false)
+		if spot == nil {
+			return
+		}
+		wake(spot.threadPtr, spot.cancel, spot.waitStrategy)
+	}
+}
+
+// take removes the first spot of a goroutine still waiting for the given turn or an earlier one, or for any turn in
+// case every is true, and claims it so that nobody else calls the goroutine, as served in case serve is true and the
+// goroutine waits for the very turn with a value to hand over
+// The spots of the goroutines which gave up waiting are dropped along the way
+// It returns nil in case there is no such spot, unlinked reports whether the parker holds no reference to the spot
+// anymore as it might be left behind as the sentinel or as the last spot
+// The goroutine is called by the caller once the lock is released
+func (tp *ThreadParker[T]) take(turn uint32, every, serve bool) (spot *waitSpot, served, unlinked bool) {
+	if tp.idle() {
+		return
+	}
+	tp.remover.Lock()
+	pred := tp.head.Load()
+	for spot = pred.next.Load(); spot != nil; spot = spot.next.Load() {
+		if spot.waiting() {
+			if !every && int32(spot.idx-turn) > 0 {
+				pred = spot
+				continue
+			}
+			served = serve && spot.handoff && spot.idx == turn
+			if spot.claim(served) {
+				_, unlinked = tp.unlink(pred, spot)
+				tp.remover.Unlock()
+				return
 			}
 		}
+		// the goroutine gave up waiting meanwhile
+		pred, _ = tp.unlink(pred, spot)
+	}
+	tp.remover.Unlock()
+	return nil, false, false
+}
+
+// prune drops the spots of the goroutines which gave up waiting or were called via other parkers
+func (tp *ThreadParker[T]) prune() {
+	if tp.idle() {
+		return
+	}
+	tp.remover.Lock()
+	pred := tp.head.Load()
+	for spot := pred.next.Load(); spot != nil; spot = spot.next.Load() {
+		if spot.waiting() {
+			pred = spot
+		} else {
+			pred, _ = tp.unlink(pred, spot)
+		}
+	}
+	tp.remover.Unlock()
+}
+
+// unlink removes the spot following pred and returns the spot which precedes the ones after it from now on
+// along with whether the spot was removed for good
+// The last spot is never removed as a park() might be appending to it concurrently, it becomes the sentinel instead
+// in case it directly follows the sentinel and is otherwise removed only once another spot follows it
+// The links of removed spots are left intact, a park() still holding one as a stale tail follows them to the tail
+func (tp *ThreadParker[T]) unlink(pred, spot *waitSpot) (*waitSpot, bool) {
+	if next := spot.next.Load(); next != nil {
+		pred.next.Store(next)
+		return pred, true
+	} else if pred == tp.head.Load() {
+		tp.head.Store(spot)
+	}
+	return spot, false
+}
+
+// waiting returns whether the goroutine of the spot is still waiting to be called, sentinels never are
+func (self *waitSpot) waiting() bool {
+	return self.cancel != nil && self.cancel.Load() == spotWaiting
+}
+
+// claim claims the spot of a waiting goroutine so that only the caller calls it, as served in case its value
+// is handed over, it returns false in case the goroutine gave up waiting meanwhile
+func (self *waitSpot) claim(served bool) bool {
+	if served {
+		return self.cancel.CompareAndSwap(spotWaiting, spotServed)
+	}
+	return self.cancel.CompareAndSwap(spotWaiting, spotClaimed)
+}
+
+// wake calls the goroutine of a spot claimed by ready() once it is flagged as parked, the goroutines which parked
+// themselves after Park() are never flagged and are called once found parked instead
+func wake(threadPtr unsafe.Pointer, cancel *parking, ws WaitStrategy) {
+	if ws == nil {
+		safe_ready(threadPtr, adaptiveSpin{})
+		return
+	}
+	flagged_ready(threadPtr, &cancel.parked, ws)
+}
+
+// parkUntil parks the current calling goroutine on the given spot until it is called by ready() or ctx is done
+// The wait strategy of the spot must be set beforehand
+// ready reports whether the awaited event already occurred, it is checked once the spot is enqueued so that
+// an event racing with the enqueue is never missed, in which case the goroutine does not park at all
+// In case ctx is non-nil, a callback is registered on it for the duration of the park via context.AfterFunc() which
+// spawns no goroutine unless ctx is done for the contexts of the standard library, before Go 1.21 a watcher goroutine
+// is spawned instead, hence a nil ctx is preferable for waiting indefinitely
+// It returns whether the value of the spot was handed over by ready()
+func (tp *ThreadParker[T]) parkUntil(ctx context.Context, spot *waitSpot, ready func() bool) (served bool) {
+	return parkUntilAny(ctx, []*ThreadParker[T]{tp}, []*waitSpot{spot}, ready)
+}
+
+// parkUntilAny parks the current calling goroutine on every given parker with the spot of the same index at once
+// until it is called by ready() on any of them or ctx is done, just like parkUntil() does on a single parker
+// The spots share their cancellation state, hence the goroutine is called only once whereas its spots left on the
+// other parkers are dropped without calling it
+func parkUntilAny[T any](ctx context.Context, parkers []*ThreadParker[T], spots []*waitSpot, ready func() bool) (served bool) {
+	// allocations might park this goroutine for a GC assist, hence they are done before enqueueing
+	// even though ready() waits for the goroutine to be flagged as parked rather than merely waiting
+	var (
+		threadPtr = GetG()
+		cancel    = new(parking)
+		ws        = spots[0].waitStrategy
+		stop      func() bool
+		park      = func(gp unsafe.Pointer) { flagged_park(gp, &cancel.parked) }
+	)
+	if ctx != nil {
+		stop = watchContext(ctx, func() {
+			if cancel.CompareAndSwap(spotWaiting, spotCancelled) {
+				flagged_ready(threadPtr, &cancel.parked, ws)
+			}
+		})
+	}
+	for idx, spot := range spots {
+		spot.threadPtr, spot.cancel = threadPtr, cancel
+		spot.next.Store(nil)
+		parkers[idx].park(spot)
+	}
+	// in case the spot was already claimed, the caller of ready() waits for this goroutine to park
+	if !ready() || !cancel.CompareAndSwap(spotWaiting, spotCancelled) {
+		mcall(park)
+	}
+	if stop != nil {
+		stop()
+	}
+	// the spots left behind by a goroutine which gave up waiting or was called via another parker are dropped right
+	// away rather than once the turns they waited for come, which might take forever on an idle queue
+	state := cancel.Load()
+	if state == spotCancelled || len(parkers) > 1 {
+		for _, parker := range parkers {
+			parker.prune()
+		}
+	}
+	return state == spotServed
+}
+
+// isDone reports whether ctx is done, a nil ctx is never done
+func isDone(ctx context.Context) bool {
+	return ctx != nil && ctx.Err() != nil
+}
+
+// cancellable returns ctx unless it is never done, in which case nil is returned so that the waits on it take the
+// paths waiting indefinitely
+func cancellable(ctx context.Context) context.Context {
+	if ctx == nil || ctx.Done() == nil {
+		return nil
 	}
+	return ctx
 }
@@ -0,0 +1,176 @@
+package zenq_test
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+func TestNewAllocatesNoSpotsPerSlot(t *testing.T) {
+	const queues = 16
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for i := 0; i < queues; i++ {
+		zq := zenq.New[int](4096)
+		zq.Free()
+	}
+	runtime.ReadMemStats(&after)
+	// parkers allocate their sentinel spots only once a goroutine parks on them
+	if mallocs := (after.Mallocs - before.Mallocs) / queues; mallocs > 64 {
+		t.Fatalf("%d allocations per queue of 4096 slots", mallocs)
+	}
+}
+
+func TestParkedReadAllocatesNoPayload(t *testing.T) {
+	const numItems = 200
+	zq, _ := zenq.NewWithOptions[[4096]byte](zenq.Options{Size: 4, WaitStrategy: zenq.Blocking{}})
+
+	go func() {
+		for i := 0; i < numItems; i++ {
+			time.Sleep(100 * time.Microsecond)
+			zq.Write([4096]byte{byte(i)})
+		}
+	}()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < numItems; i++ {
+		if item, queueOpen := zq.Read(); !queueOpen || item[0] != byte(i) {
+			t.Fatalf("read %d, queue open %t", item[0], queueOpen)
+		}
+	}
+	runtime.ReadMemStats(&after)
+	// the spots of parked readers carry no payload
+	if bytes := (after.TotalAlloc - before.TotalAlloc) / numItems; bytes > 1024 {
+		t.Fatalf("%d bytes allocated per parked read", bytes)
+	}
+}
+
+func TestReadyHandsOverParkedValues(t *testing.T) {
+	tp := zenq.NewThreadParker[int](nil)
+	if _, ok, freeable := tp.Ready(); ok || freeable != nil {
+		t.Fatal("called a goroutine on an idle parker")
+	}
+
+	const numGoroutines = 4
+	called := make(chan struct{}, numGoroutines)
+	for i := 1; i <= numGoroutines; i++ {
+		go func(i int) {
+			zenq.ParkWithValue(tp, i)
+			called <- struct{}{}
+		}(i)
+	}
+	sum := 0
+	for n := 0; n < numGoroutines; {
+		if data, ok, _ := tp.Ready(); ok {
+			sum += data
+			n++
+		} else {
+			runtime.Gosched()
+		}
+	}
+	for i := 0; i < numGoroutines; i++ {
+		select {
+		case <-called:
+		case <-time.After(5 * time.Second):
+			t.Fatal("parked goroutine never called")
+		}
+	}
+	if sum != numGoroutines*(numGoroutines+1)/2 {
+		t.Fatalf("handed over a sum of %d", sum)
+	}
+	if _, ok, _ := tp.Ready(); ok {
+		t.Fatal("called a goroutine twice")
+	}
+}
+
+// countingWaits parks right away and counts the waits of the goroutines of a queue, a goroutine called in vain
+// waits once again
+type countingWaits struct {
+	waits *atomic.Int64
+}
+
+func (self countingWaits) Wait(attempt uint32) bool {
+	self.waits.Add(1)
+	return true
+}
+
+// awaitWaits waits for the goroutines to have waited n times and reports whether they did
+func awaitWaits(ws countingWaits, n int64) bool {
+	for deadline := time.Now().Add(5 * time.Second); ws.waits.Load() < n; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			return false
+		}
+	}
+	// let the goroutines actually park
+	time.Sleep(10 * time.Millisecond)
+	return true
+}
+
+func TestReleaseCallsOnlyTheWriterOfTheNextLap(t *testing.T) {
+	const numWriters = 6
+	ws := countingWaits{new(atomic.Int64)}
+	zq, _ := zenq.NewWithOptions[int](zenq.Options{Size: 2, WaitStrategy: ws, NoSelect: true})
+	zq.Write(0)
+	zq.Write(1)
+
+	// the writers of the later laps of each slot are parked on it along with the one of the next lap
+	var wg sync.WaitGroup
+	for i := 0; i < numWriters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			zq.Write(i)
+		}(2 + i)
+	}
+	if !awaitWaits(ws, numWriters) {
+		t.Fatalf("%d of %d writers waiting", ws.waits.Load(), numWriters)
+	}
+	for i := 0; i < numWriters; i++ {
+		waits := ws.waits.Load()
+		if _, queueOpen := zq.Read(); !queueOpen {
+			t.Fatal("queue closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+		if n := ws.waits.Load() - waits; n != 0 {
+			t.Fatalf("%d writers called in vain by read %d", n, i)
+		}
+	}
+	wg.Wait()
+	for i := 0; i < 2; i++ {
+		zq.Read()
+	}
+}
+
+func TestCommitCallsOnlyTheReaderOfItsLap(t *testing.T) {
+	const numReaders = 6
+	ws := countingWaits{new(atomic.Int64)}
+	zq, _ := zenq.NewWithOptions[int](zenq.Options{Size: 2, WaitStrategy: ws, NoSelect: true})
+
+	// the readers of the later laps of each slot are parked on it along with the one of the current lap
+	var wg sync.WaitGroup
+	for i := 0; i < numReaders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			zq.Read()
+		}()
+	}
+	if !awaitWaits(ws, numReaders) {
+		t.Fatalf("%d of %d readers waiting", ws.waits.Load(), numReaders)
+	}
+	for i := 0; i < numReaders; i++ {
+		waits := ws.waits.Load()
+		zq.Write(i)
+		time.Sleep(10 * time.Millisecond)
+		if n := ws.waits.Load() - waits; n != 0 {
+			t.Fatalf("%d readers called in vain by write %d", n, i)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,106 @@
+package zenq
+
+import "time"
+
+// WaitStrategy decides how a goroutine waits for an event which has not occurred yet
+// such as a free slot for a writer or a committed value for a reader
+type WaitStrategy interface {
+	// Wait is called once per unsuccessful check of the event with attempt counting these checks from 0
+	// It either waits for a while and returns false so that the event is checked again
+	// or returns true for the calling goroutine to park until the event occurs
+	// Waits which cannot be notified, like waiting for a writer midway through its commit, yield instead of parking
+	Wait(attempt uint32) (park bool)
+}
+
+type (
+	// BusySpin keeps the cpu busy while waiting for the lowest possible latency
+	// It should only be used when the number of waiting goroutines stays below the number of cpu cores
+	// and yields on single core systems where spinning only delays the awaited goroutine
+	BusySpin struct{}
+
+	// Yielding spins for the given number of attempts and yields the processor to other goroutines thereafter
+	// It trades some latency for letting other goroutines run on the same cores
+	Yielding struct {
+		Spins uint32
+	}
+
+	// Sleeping yields for the given number of attempts and then sleeps with an exponential backoff
+	// starting at a microsecond upto MaxSleep, it suits batch pipelines which tolerate latency but not cpu burn
+	Sleeping struct {
+		Yields   uint32
+		MaxSleep time.Duration
+	}
+
+	// Blocking yields for the given number of attempts and parks the goroutine thereafter until it is notified
+	// This is the default strategy with DefaultSpinBudget as Spins
+	Blocking struct {
+		Spins uint32
+	}
+)
+
+// DefaultMaxSleep is the backoff limit of the Sleeping strategy in case its MaxSleep is not set
+const DefaultMaxSleep = time.Millisecond
+
+// Wait implements the WaitStrategy interface
+func (BusySpin) Wait(attempt uint32) bool {
+	if multicore {
+		spin(30)
+	} else {
+		mcall(gosched_m)
+	}
+	return false
+}
+
+// Wait implements the WaitStrategy interface
+func (self Yielding) Wait(attempt uint32) bool {
+	if multicore && attempt < self.Spins {
+		spin(30)
+	} else {
+		mcall(gosched_m)
+	}
+	return false
+}
+
+// Wait implements the WaitStrategy interface
+func (self Sleeping) Wait(attempt uint32) bool {
+	if attempt < self.Yields {
+		mcall(gosched_m)
+		return false
+	}
+	maxSleep := self.MaxSleep
+	if maxSleep <= 0 {
+		maxSleep = DefaultMaxSleep
+	}
+	sleep := maxSleep
+	// shifts beyond the bit length of a duration would wrap around
+	if shift := attempt - self.Yields; shift < 32 {
+		if backoff := time.Microsecond << shift; backoff < maxSleep {
+			sleep = backoff
+		}
+	}
+	time.Sleep(sleep)
+	return false
+}
+
+// Wait implements the WaitStrategy interface
+func (self Blocking) Wait(attempt uint32) bool {
+	if attempt < self.Spins {
+		mcall(gosched_m)
+		return false
+	}
+	return true
+}
+
+// adaptiveSpin spins only as long as the runtime deems spinning worthwhile and yields thereafter
+// it is used by selectors which do not belong to any single queue
+type adaptiveSpin struct{}
+
+// Wait implements the WaitStrategy interface
+func (adaptiveSpin) Wait(attempt uint32) bool {
+	if runtime_canSpin(int(attempt)) {
+		spin(30)
+	} else {
+		mcall(gosched_m)
+	}
+	return false
+}
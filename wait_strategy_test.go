@@ -0,0 +1,81 @@
+package zenq_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+func TestWaitStrategies(t *testing.T) {
+	for name, ws := range map[string]zenq.WaitStrategy{
+		"BusySpin":         zenq.BusySpin{},
+		"Yielding":         zenq.Yielding{Spins: 10},
+		"Sleeping":         zenq.Sleeping{Yields: 5, MaxSleep: 50 * time.Microsecond},
+		"SleepingDefaults": zenq.Sleeping{},
+		"Blocking":         zenq.Blocking{Spins: 100},
+		"BlockingNoSpins":  zenq.Blocking{},
+	} {
+		t.Run(name, func(t *testing.T) {
+			const writers, readers, perWriter = 3, 2, 3000
+			zq := zenq.New[int](8)
+			zq.SetWaitStrategy(ws)
+
+			var mutex sync.Mutex
+			total := 0
+			var rg sync.WaitGroup
+			for r := 0; r < readers; r++ {
+				rg.Add(1)
+				go func(r int) {
+					defer rg.Done()
+					sum := 0
+					for {
+						var item int
+						var queueOpen bool
+						// cancellable waits go through the same strategy as the plain ones
+						if r == 0 {
+							ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+							item, queueOpen, _ = zq.ReadContext(ctx)
+							cancel()
+							if !queueOpen && !zq.IsClosed() {
+								continue
+							}
+						} else {
+							item, queueOpen = zq.Read()
+						}
+						if !queueOpen {
+							break
+						}
+						sum += item
+					}
+					mutex.Lock()
+					total += sum
+					mutex.Unlock()
+				}(r)
+			}
+			var wg sync.WaitGroup
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 1; i <= perWriter; i++ {
+						if w == 0 {
+							zq.WriteContext(context.Background(), i)
+						} else {
+							zq.Write(i)
+						}
+					}
+				}(w)
+			}
+			wg.Wait()
+			zq.Close()
+			rg.Wait()
+
+			if expected := writers * perWriter * (perWriter + 1) / 2; total != expected {
+				t.Fatalf("read values summing upto %d, expected %d", total, expected)
+			}
+		})
+	}
+}
@@ -4,8 +4,9 @@
 
 // Known Limitations:-
 //
-// 1. Max queue_size = 2^16
-// 2. The queue_size is a power of 2, in case a different size is provided then queue_size is rounded up to the next greater power of 2 upto a max of 2^16
+// 1. Max queue_size = 2^30
+// 2. The queue_size is a power of 2, in case a different size is provided then queue_size is rounded up to the next greater power of 2 upto a max of 2^30
+//    unless an exact capacity is requested via NewWithOptions() in which case the ring itself is still rounded up
 
 // Suggestions:-
 //
@@ -14,8 +15,11 @@
 package zenq
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math"
+	"math/bits"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"unsafe"
@@ -33,50 +37,83 @@ const (
 	StateFullyClosed
 )
 
+// ErrClosed is returned by the non-blocking operations once the queue is closed
+var ErrClosed = errors.New("zenq: queue is closed")
+
+// DefaultSpinBudget is the number of times a waiting goroutine yields before parking as per the default Blocking strategy
+const DefaultSpinBudget = 64
+
+// MaxQueueSize is the largest queue size supported
+// it keeps the difference between writer and reader indices well within the range of int32
+const MaxQueueSize = 1 << 30
+
 // ZenQ selector state enums
 const (
 	// Open for being selected
 	SelectionOpen = iota
 	// Running state
 	SelectionRunning
+	// The queue was freed and its auxillary thread stopped, hence it is never selected anymore
+	SelectionFreed
+	// The auxillary thread found nothing to read ahead and is parked until the next commit, the selectors enqueued
+	// meanwhile are served by the writers
+	SelectionAwaiting
 )
 
 // ZenQ Slot state enums
+// every slot is owned by a single index at a time, its turn, and goes through these phases once per lap of the queue
 const (
+	// awaiting the writer of its turn
 	SlotEmpty = iota
+	// the writer of its turn is committing a value
 	SlotBusy
+	// awaiting the reader of its turn
 	SlotCommitted
+	// holds the closing commit awaiting the reader of its turn
 	SlotClosed
 )
 
+// the lower 32 bits of a slot state hold its turn and the upper ones its phase
+const phaseShift = 32
+
 type (
 	// a single slot in the queue
 	slot[T any] struct {
-		writeParker *ThreadParker[T]
-		atomic.Uint32
+		writeParker ThreadParker[T]
+		readParker  ThreadParker[T]
+		atomic.Uint64
 		item T
 	}
 
+	// state of a slot comprising of its turn and phase
+	slotState uint64
+
 	// metadata of the queue
-	metaQ struct {
+	metaQ[T any] struct {
 		globalState uint8
-		// NOTE->self: strideLength and indexMask can be further optimized to uint8 for specialized ZenQs
-		// with known data types instead of generic type
-		// using variables with lower sizes decreases memory bandwidth consumption and increases speed
-		strideLength uint16
-		indexMask    uint16
-		contents     unsafe.Pointer
-		// memory pool refs for storing and leasing parking spots for goroutines
-		alloc func() any
-		free  func(any)
+		fullPolicy  FullPolicy
+		// NOTE->self: using variables with lower sizes decreases memory bandwidth consumption and increases speed
+		// globalState and closeIndex are packed within a single word hence a 32 bit closeIndex costs nothing over a 16 bit one
+		// the index of the closing commit, only valid once the queue is not open anymore
+		closeIndex atomic.Uint32
+		// the size upto which a full queue grows on its own, 0 in case it never grows on its own
+		// and MaxQueueSize for unbounded queues whose overflow segments are limited by the indices in use instead
+		growthLimit uint32
+		// writeRing serves the latest writer indices and readRing the oldest reader indices
+		// both are the same ring unless the queue was resized and the older ring is yet to be drained
+		writeRing atomic.Pointer[ring[T]]
+		readRing  atomic.Pointer[ring[T]]
 	}
 
 	// container for the selection events among multiple queues
 	selectFactory[T any] struct {
 		selectionState atomic.Uint32
 		auxThread      unsafe.Pointer
-		backlog        atomic.Pointer[T]
-		waitList       List
+		// the auxillary thread refers to its queue via this handle only while running so that an idle queue is
+		// garbage collected once unreachable
+		auxHandle *unsafe.Pointer
+		backlog   atomic.Pointer[T]
+		waitList  List
 	}
 
 	// ZenQ is the CPU cache optimized ringbuffer implementation
@@ -88,179 +125,701 @@ type (
 		_           [constants.CacheLinePadSize - unsafe.Sizeof(atomic.Uint32{})]byte
 		readerIndex atomic.Uint32
 		_           [constants.CacheLinePadSize - unsafe.Sizeof(atomic.Uint32{})]byte
-		metaQ
-		_ [constants.CacheLinePadSize - unsafe.Sizeof(metaQ{})]byte
+		metaQ[T]
+		_ [constants.CacheLinePadSize - unsafe.Sizeof(metaQ[T]{})]byte
 		selectFactory[T]
 		_ [constants.CacheLinePadSize - unsafe.Sizeof(selectFactory[T]{})]byte
+		// waitStrategy is only consulted on the slow paths, hence it is kept apart from the metadata
+		waitStrategy WaitStrategy
+		// the number of items dropped as per the full policy of the queue
+		dropped atomic.Uint64
+		// the cause the queue was closed with via CloseWithError(), published before the state just like closeIndex
+		closeErr atomic.Pointer[error]
+		// the number of times the queue was reopened via Reopen()
+		generation atomic.Uint32
+		// the Selectors this queue is a member of which it hands over to whenever they wait
+		selectors selectorRegistry
+		// resizes and closes are rare hence their state is kept apart from the metadata as well
+		// both are serialized by the mutex
+		mutex    sync.Mutex
+		rounding Rounding
+		padSlots bool
 	}
 )
 
-// returns the next greater power of 2 relative to val
+// returns the next greater power of 2 relative to val upto a max of MaxQueueSize
+// computed from the bit length of val as Fastlog2() is not precise enough for sizes beyond 2^26
 func nextGreaterPowerOf2(val uint32) uint32 {
-	return 1 << uint32(math.Min(math.Ceil(Fastlog2(math.Max(float64(val), 1))), 16))
+	if val <= 1 {
+		return 1
+	} else if val >= MaxQueueSize {
+		return MaxQueueSize
+	}
+	return 1 << bits.Len32(val-1)
 }
 
 // New returns a new queue given its payload type passed as a generic parameter
+// The size is rounded up to the next greater power of 2 upto a max of MaxQueueSize
 func New[T any](size uint32) *ZenQ[T] {
-	var (
-		queueSize = nextGreaterPowerOf2(size)
-		contents  = make([]slot[T], queueSize, queueSize)
-		parkPool  = sync.Pool{New: func() any { return new(parkSpot[T]) }}
-	)
-	for idx := uint32(0); idx < queueSize; idx++ {
-		spot := parkPool.Get().(*parkSpot[T])
-		spot.threadPtr = nil
-		contents[idx].writeParker = NewThreadParker(spot)
+	if size == 0 {
+		size = 1
+	} else if size > MaxQueueSize {
+		size = MaxQueueSize
+	}
+	zenq, _ := NewWithOptions[T](Options{Size: size})
+	return zenq
+}
+
+// NewUnbounded returns a new queue whose writers never block as it overflows into linked segments once full
+// The size of its ring as well as its segments is rounded up to the next greater power of 2 upto a max of MaxQueueSize
+func NewUnbounded[T any](size uint32) *ZenQ[T] {
+	if size == 0 {
+		size = 1
+	} else if size > MaxQueueSize {
+		size = MaxQueueSize
+	}
+	zenq, _ := NewWithOptions[T](Options{Size: size, FullPolicy: OverflowWhenFull})
+	return zenq
+}
+
+// NewWithOptions returns a new queue configured as per the given options
+// An error wrapping ErrInvalidOptions is returned in case the options are invalid
+func NewWithOptions[T any](options Options) (*ZenQ[T], error) {
+	queueSize, capacity, growthLimit, err := options.validate()
+	if err != nil {
+		return nil, err
+	}
+	if options.WaitStrategy == nil {
+		options.WaitStrategy = Blocking{Spins: DefaultSpinBudget}
 	}
 	zenq := &ZenQ[T]{
-		metaQ: metaQ{
-			strideLength: uint16(unsafe.Sizeof(slot[T]{})),
-			contents:     unsafe.Pointer(&contents[0]),
-			alloc:        parkPool.Get,
-			free:         parkPool.Put,
-			indexMask:    uint16(queueSize - 1),
+		metaQ: metaQ[T]{
+			fullPolicy:  options.FullPolicy,
+			growthLimit: growthLimit,
 		},
 		selectFactory: selectFactory[T]{waitList: NewList()},
+		waitStrategy:  options.WaitStrategy,
+		rounding:      options.Rounding,
+		padSlots:      options.PadSlots,
 	}
-	go zenq.selectSender()
+	r := newRing[T](queueSize, capacity, options.PadSlots)
+	// indices start from 1, hence the first turn of the slot at 0 is the last index of the first lap
+	r.startAt(1)
+	zenq.writeRing.Store(r)
+	zenq.readRing.Store(r)
+	// Signal() never succeeds without the auxiliary thread, hence selections stay running until it is up
+	zenq.selectionState.Store(SelectionRunning)
+	if options.NoSelect {
+		return zenq, nil
+	}
+	zenq.auxHandle = new(unsafe.Pointer)
+	*zenq.auxHandle = unsafe.Pointer(zenq)
+	go selectSender[T](zenq.auxHandle)
+	// the auxillary thread never exits on its own, hence it is stopped once the queue turns unreachable
+	// in case it was not freed already
+	runtime.SetFinalizer(zenq, (*ZenQ[T]).Free)
 	// allow the above auxillary thread to manifest
 	mcall(gosched_m)
-	return zenq
+	return zenq, nil
 }
 
-// Size returns the number of items in the queue at any given time
-func (self *ZenQ[T]) Size() uint32 {
-	var (
-		readerIndex uint32 = self.readerIndex.Load() & uint32(self.indexMask)
-		writerIndex uint32 = self.writerIndex.Load() & uint32(self.indexMask)
-	)
-	if readerIndex > writerIndex {
-		return uint32(self.indexMask) + 2 - (readerIndex - writerIndex)
-	} else if writerIndex > readerIndex {
-		return writerIndex - readerIndex + 1
-	} else {
-		return 0
+// SetWaitStrategy sets the strategy by which readers and writers of the queue wait, Blocking by default
+// It must be called before the queue is shared among goroutines
+func (self *ZenQ[T]) SetWaitStrategy(ws WaitStrategy) {
+	self.waitStrategy = ws
+}
+
+// returns a fresh parking spot whose goroutine waits for the given turn of its slot as per the strategy of the queue
+// Spots are never recycled as a park() might still hold a removed one as a stale tail and follow its link
+func (self *ZenQ[T]) newSpot(turn uint32) *waitSpot {
+	return &waitSpot{waitStrategy: self.waitStrategy, idx: turn}
+}
+
+// returns a fresh parking spot for a writer waiting for the turn of the given index to hand its value over
+func (self *ZenQ[T]) newHandoffSpot(idx uint32, value T) *waitSpot {
+	spot := &parkSpot[T]{waitSpot: waitSpot{waitStrategy: self.waitStrategy, idx: idx, handoff: true}, value: value}
+	return &spot.waitSpot
+}
+
+// returns the state of a slot given its turn and phase
+func newSlotState(turn uint32, phase uint64) slotState {
+	return slotState(phase<<phaseShift | uint64(turn))
+}
+
+// returns the current state of the slot
+func (self *slot[T]) load() slotState {
+	return slotState(self.Load())
+}
+
+// changes the state of the slot, only ever done by the index owning its turn
+func (self *slot[T]) store(state slotState) {
+	self.Store(uint64(state))
+}
+
+// returns the index whose lap currently owns the slot
+func (self slotState) turn() uint32 {
+	return uint32(self)
+}
+
+// returns the phase of the slot within the lap of its turn
+func (self slotState) phase() uint64 {
+	return uint64(self) >> phaseShift
+}
+
+// release hands over the slot to the lap following its current turn
+// and calls the writers parked on it for that lap, the one waiting for the next lap gets its value committed right away
+// whereas the ones of later laps keep waiting
+// Once the queue is closed, the readers parked on it are called as well since no writer might commit the next lap
+func (self *ZenQ[T]) release(r *ring[T], slot *slot[T], turn uint32) {
+	next := turn + r.indexMask + 1
+	slot.store(newSlotState(next, SlotEmpty))
+	if Load8(&self.globalState) != StateOpen {
+		// the slot is checked after the state just like Close() checks it after publishing the state,
+		// hence either one of them marks the closing commit
+		if next == self.closeIndex.Load() {
+			slot.CompareAndSwap(uint64(newSlotState(next, SlotEmpty)), uint64(newSlotState(next, SlotClosed)))
+		}
+		self.readyReaders(slot, next)
+	}
+	if slot.writeParker.idle() {
+		return
+	}
+	var commit func(T) bool
+	// without room for the next lap, its writer is merely called to wait for the room instead
+	if r.roomFor(next) {
+		commit = func(value T) bool {
+			// the writer gives up on its own beyond the closing commit or once the queue is reopened whereas the reader
+			// of the next lap might have skipped it already in case the queue got closed meanwhile
+			if r.retired.Load() || self.closedForWrites(next) ||
+				!slot.CompareAndSwap(uint64(newSlotState(next, SlotEmpty)), uint64(newSlotState(next, SlotBusy))) {
+				return false
+			}
+			slot.item = value
+			self.commit(slot, next, SlotCommitted)
+			return true
+		}
+	}
+	for slot.writeParker.ready(next, commit) {
+	}
+}
+
+// commit marks the slot as committed for the given turn and calls the readers parked on it for that turn
+func (self *ZenQ[T]) commit(slot *slot[T], turn uint32, phase uint64) {
+	slot.store(newSlotState(turn, phase))
+	self.readyReaders(slot, turn)
+}
+
+// readyReaders calls the readers parked on the slot for the given turn or an earlier one so that they check it
+// once again whereas the ones of later laps keep waiting, along with the auxillary thread in case it awaits a commit
+// to read ahead
+func (self *ZenQ[T]) readyReaders(slot *slot[T], turn uint32) {
+	for slot.readParker.ready(turn, nil) {
 	}
+	self.readyAwaitingAux()
+}
+
+// readyAllReaders calls every reader parked on the slot along with the auxillary thread in case it awaits a commit
+func (self *ZenQ[T]) readyAllReaders(slot *slot[T]) {
+	slot.readParker.readyAll()
+	self.readyAwaitingAux()
+}
+
+// readyAwaitingAux calls the auxillary thread in case it awaits a commit to read ahead
+func (self *ZenQ[T]) readyAwaitingAux() {
+	if self.selectionState.Load() == SelectionAwaiting && self.selectionState.CompareAndSwap(SelectionAwaiting, SelectionRunning) {
+		self.readyAux()
+	}
+}
+
+// readable returns whether the reader of the given index can proceed without waiting any further
+func (self *ZenQ[T]) readable(slot *slot[T], idx uint32) bool {
+	state := slot.load()
+	return state.turn() == idx && (state.phase() >= SlotCommitted || state.phase() == SlotEmpty && self.skippable(idx)) ||
+		self.closedForReads(idx)
+}
+
+// skippable returns whether the given reader index may be skipped in case its slot is still empty at its turn
+// which is the case for every index before the closing commit once the queue is closed, as their writers give up
+// waiting for a slot instead of committing
+func (self *ZenQ[T]) skippable(idx uint32) bool {
+	return Load8(&self.globalState) != StateOpen && int32(idx-self.closeIndex.Load()) < 0
+}
+
+// closedForWrites returns whether the given writer index lies beyond the closing commit
+func (self *ZenQ[T]) closedForWrites(idx uint32) bool {
+	return Load8(&self.globalState) != StateOpen && int32(idx-self.closeIndex.Load()) > 0
+}
+
+// closedForReads returns whether the given reader index lies beyond the already read closing commit
+func (self *ZenQ[T]) closedForReads(idx uint32) bool {
+	return Load8(&self.globalState) == StateFullyClosed && int32(idx-self.closeIndex.Load()) > 0
+}
+
+// Size returns the number of items in the queue at any given time just like Len()
+func (self *ZenQ[T]) Size() uint32 {
+	return uint32(self.Len())
 }
 
 // Write writes a value to the queue
 // It returns whether the queue is currently open for writes or not
 // If not then it might be still open for reads, which can be checked by calling zenq.IsClosed()
 func (self *ZenQ[T]) Write(value T) (queueClosedForWrites bool) {
-	if Load8(&self.globalState) != StateOpen {
+	return self.write(nil, value) == ErrClosed
+}
+
+// WriteContext writes a value to the queue just like Write() but gives up waiting for a free slot once ctx is done
+// in which case ctx.Err() is returned and the value is not written
+func (self *ZenQ[T]) WriteContext(ctx context.Context, value T) (queueClosedForWrites bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	switch self.write(ctx, value) {
+	case ErrClosed:
 		queueClosedForWrites = true
+	case ErrCancelled:
+		err = ctx.Err()
+	}
+	return
+}
+
+// write implements the blocking writes, a nil ctx means waiting indefinitely
+// It returns ErrClosed if the queue is closed for writes, ErrFull if the value was dropped as per the DropNewest policy
+// and ErrCancelled once ctx is done
+func (self *ZenQ[T]) write(ctx context.Context, value T) error {
+	if Load8(&self.globalState) != StateOpen {
+		return ErrClosed
+	}
+	if self.sendToSelector(value) {
+		return nil
+	}
+	switch self.fullPolicy {
+	case DropNewest, DropOldest:
+		if written, queueClosedForWrites := self.writeOrDrop(value); queueClosedForWrites {
+			return ErrClosed
+		} else if !written {
+			return ErrFull
+		}
+		return nil
+	case Overwrite:
+		// writers never wait for readers, hence there is nothing to cancel
+		ctx = nil
+	}
+	if ctx = cancellable(ctx); ctx == nil {
+		if r, writerIndex := self.claim(1); self.writeAt(r, writerIndex+1, value) {
+			return ErrClosed
+		}
+		return nil
+	}
+	// an index once claimed has to be written to, hence the writer waits for a free slot before claiming one
+	for attempt := uint32(0); ; attempt++ {
+		if ok, queueClosedForWrites := self.tryWrite(value); ok {
+			return nil
+		} else if queueClosedForWrites {
+			return ErrClosed
+		} else if isDone(ctx) {
+			return ErrCancelled
+		} else if !self.waitStrategy.Wait(attempt) {
+			continue
+		}
+		r := self.writeRing.Load()
+		writerIndex := self.writerIndex.Load()
+		r = r.resolve(writerIndex + 1)
+		slot := r.slotAt(writerIndex + 1)
+		parker, awaited := r.writeParkerFor(slot, writerIndex+1)
+		parker.parkUntil(ctx, self.newSpot(awaited), func() bool {
+			return r.writable(slot, writerIndex+1) || self.writerIndex.Load() != writerIndex || !r.owns(writerIndex+1)
+		})
+	}
+}
+
+// TryWrite writes a value to the queue only if it can be done without waiting for a free slot
+// It returns ok = false if the queue is full and ErrClosed if the queue is closed for writes
+func (self *ZenQ[T]) TryWrite(value T) (ok bool, err error) {
+	if Load8(&self.globalState) != StateOpen {
+		err = ErrClosed
+		return
+	}
+	if ok = self.sendToSelector(value); ok {
 		return
 	}
+	var closed bool
+	if ok, closed = self.tryWrite(value); closed {
+		err = ErrClosed
+	}
+	return
+}
+
+// tryWrite claims the next writer index and writes to it only if its turn has already come
+func (self *ZenQ[T]) tryWrite(value T) (ok bool, queueClosedForWrites bool) {
+	if r, writerIndex, claimed := self.tryClaim(1); claimed {
+		queueClosedForWrites = self.writeAt(r, writerIndex+1, value)
+		ok = !queueClosedForWrites
+	}
+	return
+}
+
+// claim claims the next n writer indices and returns the ring loaded beforehand along with the writer index preceding them
+// Queues which grow when full only claim vacant slots so that the queue grows instead of its writers having to wait
+// unless it cannot grow any further
+func (self *ZenQ[T]) claim(n uint32) (r *ring[T], writerIndex uint32) {
+	if self.growthLimit != 0 {
+		if r, writerIndex, claimed := self.tryClaim(n); claimed {
+			return r, writerIndex
+		}
+	}
+	r = self.writeRing.Load()
+	return r, self.writerIndex.Add(n) - n
+}
+
+// tryClaim claims the next n writer indices only if all their turns have already come, growing the queue if possible
+// otherwise, and returns the ring loaded beforehand along with the writer index preceding them
+func (self *ZenQ[T]) tryClaim(n uint32) (r *ring[T], writerIndex uint32, claimed bool) {
+	// claim indices only if their turns have come, hence CAS instead of an unconditional increment
+	for {
+		r = self.writeRing.Load()
+		writerIndex = self.writerIndex.Load()
+		current := r.resolve(writerIndex + 1)
+		if vacant, stale := current.vacant(writerIndex, n); stale {
+			// the indices were claimed meanwhile
+			continue
+		} else if !vacant {
+			if self.grow(current, n) {
+				continue
+			}
+			// the slots are still occupied by a previous lap, hence the queue is full
+			return
+		}
+		// a resize might move the indices over to a new ring even now, hence writeAt() resolves their ring once again
+		if claimed = self.writerIndex.CompareAndSwap(writerIndex, writerIndex+n); claimed {
+			return
+		}
+	}
+}
 
-	// Try to send directly to selector when possible or else just dequeue unselected references
-	// in order to reduce the burden on the auxillary thread and save cpu time
-direct_send:
-	if threadPtr, dataOut := self.waitList.Dequeue(); threadPtr != nil {
+// Try to send directly to selector when possible or else just dequeue unselected references
+// in order to reduce the burden on the auxillary thread and save cpu time
+func (self *ZenQ[T]) sendToSelector(value T) (sent bool) {
+	for {
+		threadPtr, dataOut, spot := self.waitList.dequeue()
+		if threadPtr == nil {
+			break
+		}
 		if selThread := atomic.SwapPointer(threadPtr, nil); selThread != nil {
 			// direct send to selector
 			*dataOut = value
 			// notify selector
-			safe_ready(selThread)
+			readySelector(threadPtr, selThread, self.waitStrategy, spot)
+			sent = true
 			return
 		}
-		goto direct_send
 	}
+	if waiter, selThread := self.selectors.acquire(); selThread != nil {
+		waiter.handOver(self, value, selThread, self.waitStrategy)
+		sent = true
+	}
+	return
+}
 
-	slot := (*slot[T])(unsafe.Pointer(uintptr(self.strideLength)*(uintptr(self.indexMask)&uintptr(self.writerIndex.Add(1))) + uintptr(self.contents)))
+// writeAt commits a value to the slot of the given writer index once its turn comes
+// The ring must have been loaded before the index was claimed so that the ring serving the index is found from it
+// A value committed to a ring retired meanwhile is discarded along with the ring, hence the queue is reported closed
+func (self *ZenQ[T]) writeAt(r *ring[T], idx uint32, value T) (queueClosedForWrites bool) {
+	r = r.resolve(idx)
+	slot := r.slotAt(idx)
+	if served, queueClosedForWrites := self.awaitTurn(r, slot, idx, true, value); queueClosedForWrites {
+		return true
+	} else if !served {
+		slot.item = value
+		self.commit(slot, idx, SlotCommitted)
+	}
+	return r.retired.Load()
+}
 
-	// CAS -> change slot_state to busy if slot_state == empty
-	for !slot.CompareAndSwap(SlotEmpty, SlotBusy) {
-		switch slot.Load() {
-		case SlotBusy:
-			wait()
-		case SlotCommitted:
-			n := self.alloc().(*parkSpot[T])
-			n.threadPtr, n.value = GetG(), value
-			n.next.Store(nil)
-			slot.writeParker.Park(n)
-			mcall(fast_park)
+// awaitTurn waits for the turn of the given writer index to come and then marks its slot busy
+// Meanwhile the writer waits as per the strategy of the queue and when it comes to parking, the writer is parked
+// on the slot until the reader of the preceding lap calls it
+// In case of a handoff, that reader commits the value of the writer right away in which case served is true
+// Once the queue is closed, the writer gives up unless its turn has come and its slot has room already
+// whereas once the queue is reopened, the writer gives up right away as its index belongs to the older generation
+func (self *ZenQ[T]) awaitTurn(r *ring[T], slot *slot[T], idx uint32, handoff bool, value T) (served bool, queueClosedForWrites bool) {
+	for attempt := uint32(0); ; attempt++ {
+		// no reader is ever going to show up beyond the closing commit, hence the index is merely abandoned
+		if r.retired.Load() || self.closedForWrites(idx) {
+			queueClosedForWrites = true
 			return
-		case SlotEmpty:
-			continue
-		case SlotClosed:
+		}
+		// the indices before the closing commit given up on are skipped by their readers instead
+		closed := Load8(&self.globalState) != StateOpen
+		state := slot.load()
+		if turn := state.turn(); turn == idx {
+			if state.phase() != SlotEmpty {
+				// the reader of the index skipped it already
+				queueClosedForWrites = true
+				return
+			} else if r.roomFor(idx) {
+				// the reader of the index might skip it concurrently once the queue is closed, hence CAS
+				if slot.CompareAndSwap(uint64(state), uint64(newSlotState(idx, SlotBusy))) {
+					return
+				}
+			} else if closed {
+				queueClosedForWrites = true
+				return
+			} else if self.waitStrategy.Wait(attempt) {
+				parker, awaited := r.writeParkerFor(slot, idx)
+				parker.parkUntil(nil, self.newSpot(awaited), func() bool {
+					return r.roomFor(idx) || Load8(&self.globalState) != StateOpen || r.retired.Load()
+				})
+			}
+		} else if closed || int32(idx-turn) < 0 {
+			// the slot is still held by a previous lap or else the reader of the index skipped it already
+			queueClosedForWrites = true
 			return
+		} else if self.fullPolicy == Overwrite {
+			// the slot is only ever freed by a reader, hence the writer takes it over instead of waiting for one
+			// unless a reader already claimed the item in which case the reader calls the writer once done
+			if self.overwrite(r, slot, idx) {
+				slot.store(newSlotState(idx, SlotBusy))
+				return
+			} else if int32(self.readerIndex.Load()-turn) < 0 || !self.waitStrategy.Wait(attempt) {
+				backoff(self.waitStrategy, attempt)
+			} else {
+				// the writer waits for the reader to release the current turn rather than for its own turn to come
+				// which might lie more than a lap ahead
+				slot.writeParker.parkUntil(nil, self.newSpot(turn+r.indexMask+1), func() bool {
+					return slot.load().turn() != turn || Load8(&self.globalState) != StateOpen || r.retired.Load()
+				})
+			}
+		} else if self.waitStrategy.Wait(attempt) {
+			var spot *waitSpot
+			if handoff {
+				spot = self.newHandoffSpot(idx, value)
+			} else {
+				spot = self.newSpot(idx)
+			}
+			if served = slot.writeParker.parkUntil(nil, spot, func() bool {
+				return slot.load().turn() == idx || Load8(&self.globalState) != StateOpen || r.retired.Load()
+			}); served {
+				return
+			}
 		}
 	}
-	slot.item = value
-	slot.Store(SlotCommitted)
-	return
 }
 
 // Read reads a value from the queue, you can once read once per object
 func (self *ZenQ[T]) Read() (data T, queueOpen bool) {
-	slot := (*slot[T])(unsafe.Pointer(uintptr(self.strideLength)*(uintptr(self.indexMask)&uintptr(self.readerIndex.Add(1))) + uintptr(self.contents)))
-
-	// CAS -> change slot_state to busy if slot_state == committed
-	for !slot.CompareAndSwap(SlotCommitted, SlotBusy) {
-		switch slot.Load() {
-		case SlotBusy:
-			wait()
-		case SlotEmpty:
-			var freeable *parkSpot[T]
-			if data, queueOpen, freeable = slot.writeParker.Ready(); queueOpen {
-				self.free(freeable)
-				return
-			} else if Load8(&self.globalState) != StateFullyClosed {
-				mcall(gosched_m)
-			} else {
-				// queue is closed, decrement the reader index by 1
-				self.readerIndex.Add(math.MaxUint32)
-				queueOpen = false
-				return
-			}
-		case SlotClosed:
-			if slot.CompareAndSwap(SlotClosed, SlotEmpty) {
-				Store8(&self.globalState, StateFullyClosed)
+	data, queueOpen, _ = self.read(nil)
+	return
+}
+
+// ReadContext reads a value from the queue just like Read() but gives up waiting for a value once ctx is done
+// in which case ctx.Err() is returned along with queueOpen = false as nothing was read
+func (self *ZenQ[T]) ReadContext(ctx context.Context) (data T, queueOpen bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	var cancelled bool
+	if data, queueOpen, cancelled = self.read(ctx); cancelled {
+		err = ctx.Err()
+	}
+	return
+}
+
+// read implements the blocking reads, a nil ctx means waiting indefinitely
+func (self *ZenQ[T]) read(ctx context.Context) (data T, queueOpen bool, cancelled bool) {
+	// every index before the closing commit was claimed already, hence there is nothing left to claim
+	if Load8(&self.globalState) == StateFullyClosed {
+		return
+	}
+	if ctx = cancellable(ctx); ctx == nil {
+		for {
+			r := self.readRing.Load()
+			if data, queueOpen, skipped := self.readAt(r, self.readerIndex.Add(1)); !skipped {
+				return data, queueOpen, false
 			}
-			queueOpen = false
+		}
+	}
+	// an index once claimed has to be read from, hence the reader waits for a committed value before claiming one
+	for attempt := uint32(0); ; attempt++ {
+		var closed bool
+		if data, queueOpen, closed = self.tryRead(); queueOpen || closed {
+			return
+		} else if cancelled = isDone(ctx); cancelled {
 			return
-		case SlotCommitted:
+		} else if !self.waitStrategy.Wait(attempt) {
 			continue
 		}
+		r := self.readRing.Load()
+		readerIndex := self.readerIndex.Load()
+		r = r.resolve(readerIndex + 1)
+		slot := r.slotAt(readerIndex + 1)
+		slot.readParker.parkUntil(ctx, self.newSpot(readerIndex+1), func() bool {
+			return self.readable(slot, readerIndex+1) || self.readerIndex.Load() != readerIndex || !r.owns(readerIndex+1)
+		})
+	}
+}
+
+// TryRead reads a value from the queue only if one is available right away
+// It returns ok = false if the queue is empty and an error matching ErrClosed once the queue is closed and fully drained
+func (self *ZenQ[T]) TryRead() (data T, ok bool, err error) {
+	var closed bool
+	if data, ok, closed = self.tryRead(); closed {
+		err = self.closedError()
 	}
-	data, queueOpen = slot.item, true
-	slot.Store(SlotEmpty)
 	return
 }
 
+// tryRead claims the next reader index and reads from it only if its value is already committed
+func (self *ZenQ[T]) tryRead() (data T, ok bool, queueClosed bool) {
+	// claim an index only if a value is available for it, hence CAS instead of an unconditional increment
+	for {
+		r := self.readRing.Load()
+		readerIndex := self.readerIndex.Load()
+		// a committed value is only ever found in the ring serving its index, hence it cannot move over to another ring
+		state := r.resolve(readerIndex + 1).slotAt(readerIndex + 1).load()
+		turn := state.turn()
+		// an empty slot is claimed as well in case it is to be skipped
+		if turn != readerIndex+1 || state.phase() < SlotCommitted && !(state.phase() == SlotEmpty && self.skippable(turn)) {
+			if int32(turn-readerIndex-1) > 0 {
+				// the index was claimed meanwhile
+				continue
+			}
+			queueClosed = self.closedForReads(readerIndex + 1)
+			return
+		}
+		if self.readerIndex.CompareAndSwap(readerIndex, readerIndex+1) {
+			if value, open, skipped := self.readAt(r, readerIndex+1); !skipped {
+				return value, open, !open
+			}
+		}
+	}
+}
+
+// readAt reads a value from the slot of the given reader index once its turn has been committed
+// Meanwhile the reader waits as per the strategy of the queue and when it comes to parking, it is parked on the slot
+// until the writer of its turn commits
+// The ring must have been loaded before the index was claimed, unlike writers a reader ahead of the writers might
+// find its index moved over to a new ring by a resize while it waits
+// Once the queue is closed, the indices before the closing commit whose writers gave up are skipped, in which case
+// skipped is true and the reader has to claim another index
+// Once the queue is reopened, the reader finds the queue closed as its index belongs to the older generation
+func (self *ZenQ[T]) readAt(r *ring[T], idx uint32) (data T, queueOpen bool, skipped bool) {
+	r = self.readRingFor(r, idx)
+	slot := r.slotAt(idx)
+	for attempt := uint32(0); ; attempt++ {
+		if !r.owns(idx) {
+			r = self.readRingFor(r, idx)
+			slot = r.slotAt(idx)
+		}
+		if r.retired.Load() {
+			return
+		}
+		if state := slot.load(); state.turn() == idx {
+			switch state.phase() {
+			case SlotEmpty:
+				// the writer of the index might still commit concurrently, hence CAS
+				if self.skippable(idx) && slot.CompareAndSwap(uint64(state), uint64(newSlotState(idx, SlotClosed))) {
+					self.release(r, slot, idx)
+					return data, true, true
+				}
+			case SlotBusy:
+				backoff(self.waitStrategy, attempt)
+				continue
+			case SlotCommitted:
+				data, queueOpen = slot.item, true
+				self.release(r, slot, idx)
+				// a value read from a ring retired meanwhile was discarded along with the ring
+				if r.retired.Load() {
+					var zero T
+					return zero, false, false
+				}
+				return
+			case SlotClosed:
+				self.release(r, slot, idx)
+				// the queue might be reopened meanwhile, hence the state is changed only while the ring is not retired
+				self.mutex.Lock()
+				if !r.retired.Load() {
+					Store8(&self.globalState, StateFullyClosed)
+				}
+				self.mutex.Unlock()
+				// the readers beyond the closing commit might be parked on any slot of this ring or a later one
+				for ; r != nil; r = r.next.Load() {
+					r.each(self.readyAllReaders)
+				}
+				return
+			}
+		}
+		if self.closedForReads(idx) {
+			// queue is closed, the index is merely abandoned as no writer is ever going to show up beyond the closing commit
+			return
+		}
+		if self.waitStrategy.Wait(attempt) {
+			slot.readParker.parkUntil(nil, self.newSpot(idx), func() bool {
+				return self.readable(slot, idx) || !r.owns(idx) || r.retired.Load()
+			})
+		}
+	}
+}
+
+// readRingFor returns the ring serving the given reader index starting from the given one
+// and moves the read ring of the queue forward in case the index lies beyond it
+func (self *ZenQ[T]) readRingFor(r *ring[T], idx uint32) *ring[T] {
+	if next := r.resolve(idx); next != r {
+		// reader indices are claimed in order, hence every reader yet to load the read ring claims a later index
+		self.readRing.CompareAndSwap(r, next)
+		return next
+	}
+	return r
+}
+
 // Close closes the ZenQ for further writes
 // You can only read uptill the last committed write after closing
-// This function will be blocking in case the queue is full
-// ZenQ is closed from a writer goroutine by design, hence it should always be called
-// from a writer goroutine and never from a reader goroutine which might cause the reader to get blocked and hence deadlock
+// It never blocks, every goroutine waiting on the queue is called right away and the writers still waiting for a slot
+// give up and find the queue closed whereas the readers still read all the values committed before
 // It returns if the queue was already closed for writes or not
 func (self *ZenQ[T]) Close() (alreadyClosedForWrites bool) {
+	return self.CloseWithError(nil)
+}
+
+// CloseWithError closes the ZenQ for further writes just like Close() but with err as the cause
+// Readers still read uptill the last committed write and thereafter Recv(), RecvContext() and TryRecv() return an error
+// matching both ErrClosed and err, Err() returns err and Select() returns the error matching both as well
+// A nil err closes the queue just like Close()
+func (self *ZenQ[T]) CloseWithError(err error) (alreadyClosedForWrites bool) {
+	self.mutex.Lock()
 	// This ensures a ZenQ is closed only once even if this function is called multiple times making this operation safe
 	if Load8(&self.globalState) != StateOpen {
+		self.mutex.Unlock()
 		alreadyClosedForWrites = true
 		return
 	}
+	if err != nil {
+		self.closeErr.Store(&err)
+	}
+	// the index of the closing commit is published before the state so that writers finding the queue closed can rely on it
+	r := self.writeRing.Load()
+	idx := self.writerIndex.Add(1)
+	self.closeIndex.Store(idx)
 	Store8(&self.globalState, StateClosedForWrites)
-	slot := (*slot[T])(unsafe.Pointer(uintptr(self.strideLength)*(uintptr(self.indexMask)&uintptr(self.writerIndex.Add(1))) + uintptr(self.contents)))
-
-	// CAS -> change slot_state to busy if slot_state == empty
-	for !slot.CompareAndSwap(SlotEmpty, SlotBusy) {
-		switch slot.Load() {
-		case SlotBusy, SlotCommitted:
-			mcall(gosched_m)
-		case SlotEmpty:
-			continue
-		case SlotClosed:
-			return
-		}
+	self.mutex.Unlock()
+
+	// Closing commit, in case its slot is still held by a previous lap the reader releasing it commits instead
+	closing := r.resolve(idx).slotAt(idx)
+	if closing.CompareAndSwap(uint64(newSlotState(idx, SlotEmpty)), uint64(newSlotState(idx, SlotClosed))) {
+		self.readyReaders(closing, idx)
+	}
+	// the writers parked anywhere give up unless their turns have come whereas the readers parked anywhere either
+	// skip their indices, read the values committed meanwhile or find the queue closed
+	for r = self.readRing.Load(); r != nil; r = r.next.Load() {
+		r.each(func(slot *slot[T]) {
+			slot.writeParker.readyAll()
+			self.readyAllReaders(slot)
+		})
 	}
-	// Closing commit
-	slot.Store(SlotClosed)
 	return
 }
 
 // CloseAsync closes the channel asynchronously
-// Useful when an user wants to close the channel from a reader end without blocking the thread
+// Close() never blocks anymore, hence this is merely kept for compatibility
 func (self *ZenQ[T]) CloseAsync() {
 	go self.Close()
 }
@@ -269,50 +828,121 @@ func (self *ZenQ[T]) CloseAsync() {
 
 // ReadFromBackLog tries to read a data from backlog if available
 func (self *ZenQ[T]) ReadFromBackLog() (data any) {
+	data, _ = self.readBacklog()
+	return
+}
+
+// readBacklog tries to read a data from backlog if available telling a nil item apart from an empty backlog
+func (self *ZenQ[T]) readBacklog() (data any, ok bool) {
+	if value, taken := self.takeBacklog(); taken {
+		return value, true
+	}
+	return
+}
+
+// takeBacklog takes the data from backlog if available without boxing it
+func (self *ZenQ[T]) takeBacklog() (data T, ok bool) {
+	// loading beforehand spares the empty backlogs a swap which matters for selectors polling many queues
+	if self.backlog.Load() == nil {
+		return
+	}
 	if d := self.backlog.Swap(nil); d != nil {
-		data = *((*T)(d))
+		data, ok = *d, true
 	}
 	return
 }
 
 // Signal is the mechanism by which a selector notifies this ZenQ's auxillary thread to contest for the selection
 func (self *ZenQ[T]) Signal() uint8 {
-	if !self.selectionState.CompareAndSwap(SelectionOpen, SelectionRunning) {
+	// loading beforehand spares a CAS in case the auxillary thread is running which is the common case for selectors
+	// signalling many queues
+	if self.selectionState.Load() != SelectionOpen || !self.selectionState.CompareAndSwap(SelectionOpen, SelectionRunning) {
+		switch self.selectionState.Load() {
+		case SelectionFreed:
+			// a selector which enqueued itself only after the queue was freed is served by no one else
+			// and it cannot serve itself as it has to park beforehand
+			go self.serveClosed()
+		case SelectionAwaiting:
+			// the selector is served by the next writer or else by the auxillary thread called once it commits
+			return 1
+		}
 		return 0
 	} else {
-		safe_ready(self.auxThread)
+		self.readyAux()
 		return 1
 	}
 }
 
+// readyAux calls the parked auxillary thread once its selection state was switched to running by the caller
+func (self *ZenQ[T]) readyAux() {
+	// the caller refers to the queue, hence it cannot be garbage collected before the auxillary thread refers to it
+	atomic.StorePointer(self.auxHandle, unsafe.Pointer(self))
+	safe_ready(self.auxThread, self.waitStrategy)
+}
+
 // EnqueueSelector pushes a calling selector to this ZenQ's selector waitlist
 func (self *ZenQ[T]) EnqueueSelector(threadPtr *unsafe.Pointer, dataOut *any) {
 	self.waitList.Enqueue(threadPtr, dataOut)
 }
 
+// enqueueSelectorSpot pushes a calling selector to this ZenQ's selector waitlist along with the spot it parks on
+// so that it is called only once it is flagged as parked
+func (self *ZenQ[T]) enqueueSelectorSpot(spot *selectorSpot, dataOut *any) {
+	self.waitList.enqueueSpot(&spot.threadPtr, dataOut)
+}
+
+// noSelect returns whether the ZenQ was created without the auxillary thread serving selectors
+func (self *ZenQ[T]) noSelect() bool {
+	return self.auxHandle == nil
+}
+
+// registry returns the registry of the Selectors this ZenQ is a member of
+func (self *ZenQ[T]) registry() *selectorRegistry {
+	return &self.selectors
+}
+
+// pruneSelectors drops the selectors at the head of this ZenQ's selector waitlist which were served or withdrew already
+func (self *ZenQ[T]) pruneSelectors() {
+	self.waitList.Prune()
+}
+
+// Err returns the cause the queue was closed with via CloseWithError() which is nil while the queue is open
+// and in case it was closed via Close()
+func (self *ZenQ[T]) Err() error {
+	if err := self.closeErr.Load(); err != nil && Load8(&self.globalState) != StateOpen {
+		return *err
+	}
+	return nil
+}
+
+// closedError returns ErrClosed along with the cause the queue was closed with if any
+func (self *ZenQ[T]) closedError() error {
+	if err := self.Err(); err != nil {
+		return causedError{ErrClosed, err}
+	}
+	return ErrClosed
+}
+
 // IsClosed returns whether the zenq is closed for both reads and writes
 func (self *ZenQ[T]) IsClosed() bool {
 	return Load8(&self.globalState) == StateFullyClosed
 }
 
 // Reset resets the queue state
-// This also releases all parked goroutines if any and drains all committed writes
+// This also releases all parked goroutines if any and discards all committed writes just like Reopen()
 func (self *ZenQ[T]) Reset() {
-	// Close() is blocking when queue is full hence execute it asynchronously
-	self.CloseAsync()
-	// drain entire queue
-	for open := true; open; _, open = self.Read() {
-	}
-	Store8(&self.globalState, StateOpen)
+	self.Reopen()
 }
 
 // Dump dumps the current queue state
 // Unsafe to be called from multiple goroutines
 func (self *ZenQ[T]) Dump() {
 	fmt.Printf("writerIndex: %3d, readerIndex: %3d\n contents:-\n\n", self.writerIndex, self.readerIndex)
-	for idx := uintptr(0); idx <= uintptr(self.indexMask); idx++ {
-		slot := (*slot[T])(unsafe.Pointer(uintptr(self.contents) + idx*unsafe.Sizeof(slot[T]{})))
-		fmt.Printf("Slot -> %#v\n", *slot)
+	for r := self.readRing.Load(); r != nil; r = r.next.Load() {
+		for idx := uintptr(0); idx <= uintptr(r.indexMask); idx++ {
+			slot := (*slot[T])(unsafe.Pointer(uintptr(r.contents) + idx*r.strideLength))
+			fmt.Printf("Slot -> %#v\n", *slot)
+		}
 	}
 }
 
@@ -320,7 +950,9 @@ func (self *ZenQ[T]) Dump() {
 // only when a selector sends a signal, it is notified and tries to send back to the selector
 // if it fails, then it parks again and waits for another signal from another selection process
 // since it is parked most of the times, it consumes minimal cpu time making the selection process efficient
-func (self *ZenQ[T]) selectSender() {
+// It refers to its queue only via the given handle while running and exits once the queue is freed
+func selectSender[T any](handle *unsafe.Pointer) {
+	self := (*ZenQ[T])(atomic.LoadPointer(handle))
 	atomic.StorePointer(&self.auxThread, GetG())
 	var (
 		data                 T
@@ -328,32 +960,69 @@ func (self *ZenQ[T]) selectSender() {
 		readState, queueOpen bool = false, true
 		selectorThread       *unsafe.Pointer
 		dataOut              *any
+		spot                 bool
 	)
 
 	for {
-		// park by default and wait for Signal() notification from a selection process
-		mcall(fast_park)
-		if !readState {
-			data, queueOpen = self.Read()
-			readState = true
+		// the handle is cleared before the selection is open so that it never clears the queue stored by Signal()
+		atomic.StorePointer(handle, nil)
+		// a selector signalling meanwhile waits for this thread to park whereas a freed queue stops it
+		if !self.selectionState.CompareAndSwap(SelectionRunning, SelectionOpen) {
+			return
+		}
+		// selectors enqueued meanwhile might have found this thread running and hence sent no signal,
+		// they are served right away instead of waiting for another selection process to signal
+		if self.waitList.Empty() && !self.selectors.waiting() || !self.selectionState.CompareAndSwap(SelectionOpen, SelectionRunning) {
+			// park by default and wait for Signal() notification from a selection process
+			mcall(fast_park)
+			if self = (*ZenQ[T])(atomic.LoadPointer(handle)); self == nil {
+				// woken up by Free() instead
+				return
+			}
+		}
+		// the data committed to the backlog is either taken back or was read by a selector meanwhile
+		if readState && queueOpen && self.backlog.Swap(nil) == nil {
+			readState = false
+		}
+		for !readState {
+			var read, closed bool
+			if data, read, closed = self.tryRead(); read || closed {
+				queueOpen, readState = read, true
+				break
+			}
+			// nothing to read ahead, hence this thread parks until the next commit instead of waiting in Read()
+			// as it would refer to the queue meanwhile which could never be garbage collected then
+			atomic.StorePointer(handle, nil)
+			if !self.selectionState.CompareAndSwap(SelectionRunning, SelectionAwaiting) {
+				return
+			}
+			// a commit racing the transition found this thread running, hence the slot is checked once again
+			r, readerIndex := self.readRing.Load(), self.readerIndex.Load()
+			if !self.readable(r.resolve(readerIndex+1).slotAt(readerIndex+1), readerIndex+1) ||
+				!self.selectionState.CompareAndSwap(SelectionAwaiting, SelectionRunning) {
+				mcall(fast_park)
+				if self = (*ZenQ[T])(atomic.LoadPointer(handle)); self == nil {
+					// woken up by Free() instead
+					return
+				}
+			}
 		}
 
 	selector_dequeue:
 		for {
 			// keep dequeuing selectors from waitlist and try to acquire one
 			// if acquired write to selector, ready it and go back to parking state
-			if selectorThread, dataOut = self.waitList.Dequeue(); selectorThread != nil {
+			if selectorThread, dataOut, spot = self.waitList.dequeue(); selectorThread != nil {
 				if threadPtr = atomic.SwapPointer(selectorThread, nil); threadPtr != nil {
 					// implementaion of sending from closed channel to selector mechanism
 					if queueOpen {
 						// write to the selector
 						*dataOut = data
 					} else {
-						// send nil from closed channel
-						*dataOut = nil
+						*dataOut = self.closedSelection()
 					}
 					// notify selector
-					safe_ready(threadPtr)
+					readySelector(selectorThread, threadPtr, self.waitStrategy, spot)
 					readState = false
 					break selector_dequeue
 				} else {
@@ -363,12 +1032,31 @@ func (self *ZenQ[T]) selectSender() {
 				break selector_dequeue
 			}
 		}
+		// the Selectors registered are served only after the selectors enqueued as they wait on every call
+		if readState {
+			if waiter, threadPtr := self.selectors.acquire(); threadPtr != nil {
+				if queueOpen {
+					waiter.handOver(self, data, threadPtr, self.waitStrategy)
+				} else {
+					waiter.handOver(self, self.closedSelection(), threadPtr, self.waitStrategy)
+				}
+				readState = false
+			}
+		}
 		// if not selected by any selector, commit data to backlog and wait for next signal
 		// saves a lot of cpu time
 		if readState && queueOpen {
 			var i T = data
 			self.backlog.Store(&i)
+			// the readers parked via SelectOf() found the backlog empty and wait on the slot of the next read
+			r, readerIndex := self.readRing.Load(), self.readerIndex.Load()
+			self.readyReaders(r.resolve(readerIndex+1).slotAt(readerIndex+1), readerIndex+1)
 		}
-		self.selectionState.Store(SelectionOpen)
 	}
 }
+
+// closedSelection returns what a selector receives from a closed queue
+// along with the cause in case it was closed via CloseWithError()
+func (self *ZenQ[T]) closedSelection() any {
+	return closedStream{self.Err()}
+}
@@ -0,0 +1,732 @@
+package zenq_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alphadose/zenq/v2"
+)
+
+// payloads whose slots are larger than 64 KiB
+type (
+	largeArray [1 << 17]byte
+
+	largeStruct struct {
+		header  int64
+		blob    [70000]byte
+		name    string
+		next    *largeStruct
+		trailer int64
+	}
+)
+
+func TestLargeArrayPayload(t *testing.T) {
+	const numItems = 10
+	zq := zenq.New[largeArray](4)
+
+	go func() {
+		for i := 0; i < numItems; i++ {
+			var item largeArray
+			for j := range item {
+				item[j] = byte(i + j)
+			}
+			zq.Write(item)
+		}
+	}()
+
+	for i := 0; i < numItems; i++ {
+		item, queueOpen := zq.Read()
+		if !queueOpen {
+			t.Fatalf("queue closed after %d reads", i)
+		}
+		for j := range item {
+			if item[j] != byte(i+j) {
+				t.Fatalf("item %d corrupted at byte %d: got %d want %d", i, j, item[j], byte(i+j))
+			}
+		}
+	}
+}
+
+func TestLargeStructPayload(t *testing.T) {
+	const numItems = 10
+	zq := zenq.New[largeStruct](4)
+
+	go func() {
+		for i := 0; i < numItems; i++ {
+			item := largeStruct{header: int64(i), name: strconv.Itoa(i), next: &largeStruct{trailer: int64(i)}, trailer: -int64(i)}
+			item.blob[len(item.blob)-1] = byte(i)
+			zq.Write(item)
+			// the pointers held by the queued items must survive a GC cycle
+			runtime.GC()
+		}
+	}()
+
+	for i := 0; i < numItems; i++ {
+		item, queueOpen := zq.Read()
+		if !queueOpen {
+			t.Fatalf("queue closed after %d reads", i)
+		}
+		if item.header != int64(i) || item.trailer != -int64(i) || item.blob[len(item.blob)-1] != byte(i) {
+			t.Fatalf("item %d corrupted: header %d trailer %d", i, item.header, item.trailer)
+		}
+		if item.name != strconv.Itoa(i) || item.next == nil || item.next.trailer != int64(i) {
+			t.Fatalf("item %d lost its references", i)
+		}
+	}
+}
+
+func TestWriteContextCancelled(t *testing.T) {
+	zq := zenq.New[int](2)
+	zq.Write(1)
+	zq.Write(2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if closed, err := zq.WriteContext(ctx, 3); closed || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wrote to a full queue, closed %t: %v", closed, err)
+	}
+
+	// the slot given up on is skipped by the readers
+	for _, expected := range []int{1, 2} {
+		if item, _ := zq.Read(); item != expected {
+			t.Fatalf("read %d, expected %d", item, expected)
+		}
+	}
+	zq.Write(4)
+	if item, _ := zq.Read(); item != 4 {
+		t.Fatalf("read %d, expected 4", item)
+	}
+}
+
+func TestReadContextCancelled(t *testing.T) {
+	zq := zenq.New[int](4)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, queueOpen, err := zq.ReadContext(ctx); queueOpen || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("read from an empty queue, queue open %t: %v", queueOpen, err)
+	}
+
+	// values written afterwards are not lost to the read given up on
+	zq.Write(7)
+	zq.Write(8)
+	for _, expected := range []int{7, 8} {
+		if item, _ := zq.Read(); item != expected {
+			t.Fatalf("read %d, expected %d", item, expected)
+		}
+	}
+}
+
+func TestTimeoutsLeaveNoSpotsBehind(t *testing.T) {
+	const timeouts = 200
+	// the goroutines park right away
+	zq, _ := zenq.NewWithOptions[int](zenq.Options{Size: 2, WaitStrategy: zenq.Blocking{}})
+
+	for i := 0; i < timeouts; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Microsecond)
+		if _, queueOpen, err := zq.ReadContext(ctx); queueOpen || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("read from an empty queue, queue open %t: %v", queueOpen, err)
+		}
+		cancel()
+	}
+	zq.Write(1)
+	zq.Write(2)
+	for i := 0; i < timeouts; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Microsecond)
+		if closed, err := zq.WriteContext(ctx, 3); closed || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("wrote to a full queue, closed %t: %v", closed, err)
+		}
+		cancel()
+	}
+
+	// the spots given up on are dropped right away instead of piling up until the turns they waited for come
+	if n := zenq.LinkedSpots(zq); n > 2 {
+		t.Fatalf("%d spots left behind by %d timeouts", n, 2*timeouts)
+	}
+	for _, expected := range []int{1, 2} {
+		if item, _ := zq.Read(); item != expected {
+			t.Fatalf("read %d, expected %d", item, expected)
+		}
+	}
+}
+
+func TestContextCancelledWhileContending(t *testing.T) {
+	const writers, readers, perWriter = 8, 4, 2000
+	zq := zenq.New[int](4)
+
+	var (
+		mutex   sync.Mutex
+		written = make(map[int]bool)
+		read    = make(map[int]int)
+	)
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Duration(i%50)*time.Microsecond)
+				value := w*perWriter + i
+				if _, err := zq.WriteContext(ctx, value); err == nil {
+					mutex.Lock()
+					written[value] = true
+					mutex.Unlock()
+				}
+				cancel()
+			}
+		}(w)
+	}
+	stop := make(chan struct{})
+	var rg sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		rg.Add(1)
+		go func() {
+			defer rg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Microsecond)
+				if value, queueOpen, err := zq.ReadContext(ctx); err == nil && queueOpen {
+					mutex.Lock()
+					read[value]++
+					mutex.Unlock()
+				}
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		mutex.Lock()
+		drained := len(read) >= len(written)
+		mutex.Unlock()
+		if drained {
+			break
+		}
+	}
+	close(stop)
+	rg.Wait()
+
+	// every value written is read exactly once whereas the ones given up on are never read
+	for value := range written {
+		if read[value] != 1 {
+			t.Fatalf("value %d read %d times", value, read[value])
+		}
+	}
+	for value, n := range read {
+		if !written[value] || n != 1 {
+			t.Fatalf("value %d read %d times but written %t", value, n, written[value])
+		}
+	}
+}
+
+func benchmarkPingPong(b *testing.B, read func(zq *zenq.ZenQ[int])) {
+	ping, pong := zenq.New[int](2), zenq.New[int](2)
+	go func() {
+		for {
+			item, queueOpen := ping.Read()
+			if !queueOpen {
+				return
+			}
+			pong.Write(item)
+		}
+	}()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ping.Write(i)
+		read(pong)
+	}
+	b.StopTimer()
+	ping.Close()
+}
+
+func BenchmarkPingPongRead(b *testing.B) {
+	benchmarkPingPong(b, func(zq *zenq.ZenQ[int]) { zq.Read() })
+}
+
+func BenchmarkPingPongReadContext(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	benchmarkPingPong(b, func(zq *zenq.ZenQ[int]) { zq.ReadContext(ctx) })
+}
+
+func TestTryReadTryWrite(t *testing.T) {
+	const size = 4
+	zq := zenq.New[int](size)
+	if _, ok, err := zq.TryRead(); ok || err != nil {
+		t.Fatalf("read from an empty queue, ok %t: %v", ok, err)
+	}
+	for i := 0; i < size; i++ {
+		if ok, err := zq.TryWrite(i); !ok || err != nil {
+			t.Fatalf("write %d, ok %t: %v", i, ok, err)
+		}
+	}
+	if ok, err := zq.TryWrite(size); ok || err != nil {
+		t.Fatalf("wrote to a full queue, ok %t: %v", ok, err)
+	}
+	for i := 0; i < size; i++ {
+		if item, ok, err := zq.TryRead(); !ok || err != nil || item != i {
+			t.Fatalf("read %d, ok %t: %v", item, ok, err)
+		}
+	}
+
+	// a closed queue is still drained before it reports being closed
+	zq.Write(5)
+	zq.Close()
+	if ok, err := zq.TryWrite(6); ok || !errors.Is(err, zenq.ErrClosed) {
+		t.Fatalf("wrote to a closed queue, ok %t: %v", ok, err)
+	}
+	if item, ok, err := zq.TryRead(); !ok || err != nil || item != 5 {
+		t.Fatalf("read %d, ok %t: %v", item, ok, err)
+	}
+	if _, ok, err := zq.TryRead(); ok || !errors.Is(err, zenq.ErrClosed) {
+		t.Fatalf("read from a drained closed queue, ok %t: %v", ok, err)
+	}
+}
+
+func TestTryReadTryWriteWhileContending(t *testing.T) {
+	const writers, readers, perWriter = 4, 4, 20000
+	zq := zenq.New[int](8)
+
+	var written, writtenSum, read, readSum atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				// some of the writes rejected for a full queue are retried as blocking ones
+				if ok, _ := zq.TryWrite(i); ok || i%3 == 0 {
+					if !ok {
+						zq.Write(i)
+					}
+					written.Add(1)
+					writtenSum.Add(int64(i))
+				}
+			}
+		}()
+	}
+	var done atomic.Bool
+	var rg sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		rg.Add(1)
+		go func() {
+			defer rg.Done()
+			for !done.Load() || read.Load() < written.Load() {
+				if item, ok, _ := zq.TryRead(); ok {
+					read.Add(1)
+					readSum.Add(int64(item))
+				} else {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	done.Store(true)
+	rg.Wait()
+
+	if read.Load() != written.Load() || readSum.Load() != writtenSum.Load() {
+		t.Fatalf("read %d values summing upto %d out of %d summing upto %d",
+			read.Load(), readSum.Load(), written.Load(), writtenSum.Load())
+	}
+}
+
+func TestSizesBeyond64K(t *testing.T) {
+	// every slot of a large queue is filled before it reports being full, other sizes are rounded up
+	for size, capacity := range map[uint32]int{1 << 16: 1 << 16, 1<<16 + 1: 1 << 17, 70000: 1 << 17, 1 << 20: 1 << 20} {
+		zq := zenq.New[int](size)
+		for i := 0; i < capacity; i++ {
+			if ok, _ := zq.TryWrite(i); !ok {
+				t.Fatalf("queue of size %d full after %d writes", size, i)
+			}
+		}
+		if ok, _ := zq.TryWrite(capacity); ok {
+			t.Fatalf("wrote beyond the capacity %d of a queue of size %d", capacity, size)
+		}
+		for i := 0; i < capacity; i++ {
+			if item, _ := zq.Read(); item != i {
+				t.Fatalf("read %d, expected %d", item, i)
+			}
+		}
+	}
+}
+
+func TestUnboundedWritesNeverBlock(t *testing.T) {
+	const numItems = 100000
+	zq := zenq.NewUnbounded[int](4)
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for i := 0; i < numItems; i++ {
+			switch i % 4 {
+			case 0, 1:
+				zq.Write(i)
+			case 2:
+				if ok, err := zq.TryWrite(i); !ok || err != nil {
+					t.Errorf("write %d, ok %t: %v", i, ok, err)
+				}
+			case 3:
+				item, seq, _ := zq.Claim()
+				*item = i
+				zq.Publish(seq)
+			}
+		}
+	}()
+	select {
+	case <-written:
+	case <-time.After(20 * time.Second):
+		t.Fatal("writer blocked")
+	}
+	if _, closed := zq.WriteBatch([]int{numItems, numItems + 1, numItems + 2, numItems + 3, numItems + 4}); closed {
+		t.Fatal("queue closed")
+	}
+	first, err := zq.ClaimBatch(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := 0; k < 3; k++ {
+		*zq.Item(first.Add(k)) = numItems + 5 + k
+	}
+	zq.PublishBatch(first, 3)
+
+	// the overflow segments are drained in order
+	for i := 0; i < numItems+8; i++ {
+		if item, queueOpen := zq.Read(); !queueOpen || item != i {
+			t.Fatalf("read %d, expected %d, queue open %t", item, i, queueOpen)
+		}
+	}
+	zq.Close()
+	if _, queueOpen := zq.Read(); queueOpen {
+		t.Fatal("read from a drained closed queue")
+	}
+}
+
+func TestUnboundedWhileStreaming(t *testing.T) {
+	zq := zenq.NewUnbounded[int](2)
+	streamWhileResizing(t, zq, 4, 20000, func(int) {})
+
+	for i := 0; i < 1000; i++ {
+		zq.Write(i)
+	}
+	for i := 0; i < 1000; i++ {
+		if item, _ := zq.Read(); item != i {
+			t.Fatalf("read %d, expected %d", item, i)
+		}
+	}
+
+	for _, options := range []zenq.Options{
+		{Size: 8, FullPolicy: zenq.OverflowWhenFull, GrowthLimit: 16},
+		{Size: 8, FullPolicy: zenq.OverflowWhenFull, Rounding: zenq.ExactCapacity},
+	} {
+		if _, err := zenq.NewWithOptions[int](options); !errors.Is(err, zenq.ErrInvalidOptions) {
+			t.Fatalf("options %+v: %v", options, err)
+		}
+	}
+}
+
+func TestCloseWithError(t *testing.T) {
+	cause := errors.New("producer failed")
+	zq := zenq.New[int](4)
+	zq.Write(1)
+	if err := zq.Err(); err != nil {
+		t.Fatalf("open queue failed: %v", err)
+	}
+	if zq.CloseWithError(cause) {
+		t.Fatal("queue closed already")
+	}
+	// only the first cause is kept
+	if !zq.CloseWithError(errors.New("another cause")) || !zq.Close() {
+		t.Fatal("queue not closed already")
+	}
+	if err := zq.Err(); err != cause {
+		t.Fatalf("queue failed: %v", err)
+	}
+
+	// the cause is reported only once the queue is drained
+	if item, err := zq.Recv(); item != 1 || err != nil {
+		t.Fatalf("received %d: %v", item, err)
+	}
+	if _, err := zq.Recv(); !errors.Is(err, zenq.ErrClosed) || !errors.Is(err, cause) || err == zenq.ErrClosed {
+		t.Fatalf("received from a drained queue: %v", err)
+	}
+	if _, err := zq.TryRecv(); !errors.Is(err, cause) {
+		t.Fatalf("received from a drained queue: %v", err)
+	}
+	if _, _, err := zq.TryRead(); !errors.Is(err, zenq.ErrClosed) || !errors.Is(err, cause) {
+		t.Fatalf("read from a drained queue: %v", err)
+	}
+	if _, err := zq.RecvContext(context.Background()); !errors.Is(err, cause) {
+		t.Fatalf("received from a drained queue: %v", err)
+	}
+
+	closed := zenq.New[int](4)
+	closed.Close()
+	if _, err := closed.Recv(); err != zenq.ErrClosed || closed.Err() != nil {
+		t.Fatalf("received from a queue closed without a cause: %v", err)
+	}
+
+	// selectors waiting on the queue get the cause whereas plain closings still select nil
+	selected := make(chan any)
+	failing := zenq.New[int](4)
+	go func() { selected <- zenq.Select(failing) }()
+	failing.CloseWithError(cause)
+	if data := <-selected; data == nil {
+		t.Fatal("selected nil")
+	} else if err, ok := data.(error); !ok || !errors.Is(err, zenq.ErrClosed) || !errors.Is(err, cause) {
+		t.Fatalf("selected %v", data)
+	}
+	go func() { selected <- zenq.Select(closed) }()
+	if data := <-selected; data != nil {
+		t.Fatalf("selected %v", data)
+	}
+
+	// and the cause is cleared by a reset
+	failing.Reset()
+	if err := failing.Err(); err != nil {
+		t.Fatalf("queue reset failed: %v", err)
+	}
+}
+
+func TestCloseDoesNotBlock(t *testing.T) {
+	const waitingWriters = 8
+	zq := zenq.New[int](4)
+	for i := 1; i <= 4; i++ {
+		zq.Write(i)
+	}
+	var wg sync.WaitGroup
+	var failedWrites atomic.Int32
+	for i := 0; i < waitingWriters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if zq.Write(100) {
+				failedWrites.Add(1)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		zq.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close() blocked on a full queue")
+	}
+	// the writers waiting for room are woken up and fail
+	wg.Wait()
+	if n := failedWrites.Load(); n != waitingWriters {
+		t.Fatalf("%d out of %d waiting writes failed", n, waitingWriters)
+	}
+	for i := 1; i <= 4; i++ {
+		if item, queueOpen := zq.Read(); !queueOpen || item != i {
+			t.Fatalf("read %d, queue open %t", item, queueOpen)
+		}
+	}
+	if _, queueOpen := zq.Read(); queueOpen || !zq.IsClosed() || zq.Len() != 0 {
+		t.Fatalf("queue of length %d open once drained", zq.Len())
+	}
+
+	// the slots given up on leave the queue consistent once reset
+	zq.Reset()
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 4; i++ {
+			zq.Write(i)
+		}
+		for i := 0; i < 4; i++ {
+			if item, _ := zq.Read(); item != i {
+				t.Fatalf("read %d, expected %d", item, i)
+			}
+		}
+	}
+}
+
+func TestCloseWakesParkedReaders(t *testing.T) {
+	zq := zenq.New[int](4)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if item, queueOpen := zq.Read(); queueOpen {
+				t.Errorf("read %d from a closed queue", item)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	zq.Close()
+	wg.Wait()
+
+	// and so are the selectors
+	selected := make(chan any)
+	selectable := zenq.New[int](4)
+	go func() { selected <- zenq.Select(selectable) }()
+	time.Sleep(20 * time.Millisecond)
+	selectable.Close()
+	select {
+	case data := <-selected:
+		if data != nil {
+			t.Fatalf("selected %v", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("selector still waiting")
+	}
+}
+
+// closeWhileStreaming closes the queue while every kind of writer and reader is underway and checks that every
+// value written before the closing is read exactly once
+func closeWhileStreaming(t *testing.T, zq *zenq.ZenQ[int]) {
+	var (
+		mutex   sync.Mutex
+		written = make(map[int]int)
+		read    = make(map[int]int)
+		// the values of batches written partly are accounted for by their number only
+		batched int
+	)
+	record := func(m map[int]int, values ...int) {
+		mutex.Lock()
+		for _, value := range values {
+			m[value]++
+		}
+		mutex.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i += 4 {
+				value := w<<24 | i
+				switch w {
+				case 0:
+					if zq.Write(value) {
+						return
+					}
+				case 1:
+					n, closed := zq.WriteBatch([]int{value, value + 1, value + 2, value + 3})
+					mutex.Lock()
+					batched += n
+					mutex.Unlock()
+					if closed {
+						return
+					}
+					continue
+				case 2:
+					item, seq, closed := zq.Claim()
+					if closed {
+						return
+					}
+					*item = value
+					zq.Publish(seq)
+				case 3:
+					if closed, _ := zq.WriteContext(context.Background(), value); closed {
+						return
+					}
+				}
+				record(written, value)
+			}
+		}(w)
+	}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			batch := make([]int, 3)
+			for {
+				switch r {
+				case 0:
+					item, queueOpen := zq.Read()
+					if !queueOpen {
+						return
+					}
+					record(read, item)
+				case 1:
+					n, queueOpen := zq.ReadBatch(batch)
+					if !queueOpen {
+						return
+					}
+					record(read, batch[:n]...)
+				case 2:
+					item, ok, err := zq.TryRead()
+					if err != nil {
+						return
+					} else if ok {
+						record(read, item)
+					} else {
+						runtime.Gosched()
+					}
+				case 3:
+					item, queueOpen, _ := zq.ReadContext(context.Background())
+					if !queueOpen {
+						return
+					}
+					record(read, item)
+				}
+			}
+		}(r)
+	}
+	time.Sleep(3 * time.Millisecond)
+	zq.Close()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("writers or readers still waiting on a closed queue")
+	}
+
+	readFromBatches := 0
+	for value, n := range read {
+		if n != 1 {
+			t.Fatalf("read %d %d times", value, n)
+		} else if value>>24 == 1 {
+			readFromBatches++
+		} else if written[value] != 1 {
+			t.Fatalf("read %d which was never written", value)
+		}
+	}
+	for value := range written {
+		if read[value] != 1 {
+			t.Fatalf("value %d written before the closing was lost", value)
+		}
+	}
+	if readFromBatches != batched {
+		t.Fatalf("read %d out of %d values written in batches", readFromBatches, batched)
+	}
+	if n := zq.Len(); n != 0 {
+		t.Fatalf("length %d once drained", n)
+	}
+}
+
+func TestCloseWhileStreaming(t *testing.T) {
+	for round := 0; round < 10; round++ {
+		closeWhileStreaming(t, zenq.New[int](4))
+		closeWhileStreaming(t, zenq.New[int](1))
+		exact, _ := zenq.NewWithOptions[int](zenq.Options{Size: 3, Rounding: zenq.ExactCapacity})
+		closeWhileStreaming(t, exact)
+		growing, _ := zenq.NewWithOptions[int](zenq.Options{Size: 2, GrowthLimit: 16})
+		closeWhileStreaming(t, growing)
+		resized := zenq.New[int](2)
+		go resized.Resize(16)
+		closeWhileStreaming(t, resized)
+	}
+}